@@ -0,0 +1,184 @@
+/*
+Command golden-review accepts or rejects the pending golden file updates
+written by `go test -update=review`.
+
+Each pending file is shown as a unified diff from the current golden file.
+Accepting the change replaces the golden file with the pending file, rejecting
+the change removes the pending file.
+
+Usage:
+
+	golden-review [-accept-all] [-reject-all] [path ...]
+
+Each path is a directory that is searched recursively for pending files. Paths
+may use the ./... form used by the go command. The default path is the current
+directory. Only files in a testdata directory are considered pending files,
+so other files with a .new suffix are never replaced.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dnephin/vt/golden"
+	"github.com/dnephin/vt/internal/format"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "golden-review: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	acceptAll bool
+	rejectAll bool
+	paths     []string
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("golden-review", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	opts := options{}
+	flags.BoolVar(&opts.acceptAll, "accept-all", false, "accept all pending changes without prompting")
+	flags.BoolVar(&opts.rejectAll, "reject-all", false, "reject all pending changes without prompting")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if opts.acceptAll && opts.rejectAll {
+		return errors.New("-accept-all and -reject-all can not be used together")
+	}
+	opts.paths = flags.Args()
+	if len(opts.paths) == 0 {
+		opts.paths = []string{"."}
+	}
+
+	pending, err := findPending(opts.paths)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(stdout, "No pending golden files.")
+		return nil
+	}
+
+	r := reviewer{in: bufio.NewScanner(stdin), out: stdout, opts: opts}
+	for i, filename := range pending {
+		fmt.Fprintf(stdout, "\n[%d/%d] %v\n", i+1, len(pending), filename)
+		done, err := r.review(filename)
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPending returns the sorted list of pending files in paths.
+func findPending(paths []string) ([]string, error) {
+	var pending []string
+	for _, path := range paths {
+		root := filepath.FromSlash(strings.TrimSuffix(filepath.ToSlash(path), "/..."))
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.IsDir() && p != root && isIgnoredDir(d.Name()):
+				return filepath.SkipDir
+			case d.Type().IsRegular() && strings.HasSuffix(p, golden.PendingSuffix) && inTestdata(p):
+				pending = append(pending, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
+
+// inTestdata returns true if path is in a testdata directory, where golden
+// files are stored.
+func inTestdata(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if part == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+func isIgnoredDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "vendor"
+}
+
+type reviewer struct {
+	in   *bufio.Scanner
+	out  io.Writer
+	opts options
+}
+
+// review prompts for a decision about the pending file, and applies the
+// decision. Returns true if the user requested to quit.
+func (r reviewer) review(pendingFilename string) (bool, error) {
+	filename := strings.TrimSuffix(pendingFilename, golden.PendingSuffix)
+	got, err := os.ReadFile(pendingFilename)
+	if err != nil {
+		return false, err
+	}
+	want, err := os.ReadFile(filename)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		fmt.Fprintln(r.out, "New golden file.")
+	case err != nil:
+		return false, err
+	}
+
+	diff := format.UnifiedDiff(format.DiffConfig{
+		A:    string(want),
+		B:    string(got),
+		From: filename,
+		To:   pendingFilename,
+	})
+	fmt.Fprint(r.out, diff)
+
+	switch {
+	case r.opts.acceptAll:
+		return false, accept(filename, pendingFilename)
+	case r.opts.rejectAll:
+		return false, os.Remove(pendingFilename)
+	}
+
+	for {
+		fmt.Fprint(r.out, "Accept change? [a]ccept, [r]eject, [s]kip, [q]uit: ")
+		if !r.in.Scan() {
+			fmt.Fprintln(r.out)
+			return true, r.in.Err()
+		}
+		switch strings.ToLower(strings.TrimSpace(r.in.Text())) {
+		case "a", "accept", "y", "yes":
+			return false, accept(filename, pendingFilename)
+		case "r", "reject", "n", "no":
+			return false, os.Remove(pendingFilename)
+		case "s", "skip":
+			return false, nil
+		case "q", "quit":
+			return true, nil
+		}
+	}
+}
+
+func accept(filename, pendingFilename string) error {
+	if err := os.Rename(pendingFilename, filename); err != nil {
+		return fmt.Errorf("failed to accept change: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dnephin/vt/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := fs.NewDir(t, t.Name(),
+		fs.WithDir("pkg",
+			fs.WithDir("testdata",
+				fs.WithFile("changed.golden", "one\ntwo\n"),
+				fs.WithFile("changed.golden.new", "one\nthree\n"),
+				fs.WithFile("created.golden.new", "new\n"),
+				fs.WithFile("rejected.golden", "old\n"),
+				fs.WithFile("rejected.golden.new", "wrong\n")),
+			fs.WithFile("config.json", "{}"),
+			fs.WithFile("config.json.new", "not a golden file")),
+		fs.WithDir(".git",
+			fs.WithFile("ignored.new", "")))
+
+	stdin := strings.NewReader("a\nbogus\na\nr\n")
+	stdout := new(bytes.Buffer)
+	err := run([]string{dir.Path() + "/..."}, stdin, stdout)
+	assert.NilError(t, err)
+
+	assert.Assert(t, strings.Contains(stdout.String(), "-two\n+three\n"), stdout.String())
+	assert.Assert(t, strings.Contains(stdout.String(), "[3/3]"), stdout.String())
+
+	expected := fs.NewManifest(t,
+		fs.WithDir("pkg",
+			fs.WithDir("testdata",
+				fs.WithFile("changed.golden", "one\nthree\n"),
+				fs.WithFile("created.golden", "new\n"),
+				fs.WithFile("rejected.golden", "old\n")),
+			fs.WithFile("config.json", "{}"),
+			fs.WithFile("config.json.new", "not a golden file")),
+		fs.WithDir(".git",
+			fs.WithFile("ignored.new", "")))
+	assert.NilError(t, fs.PathMatchesManifest(dir.Path(), expected))
+}
+
+func TestRun_AcceptAll(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "testdata"), 0o755))
+	filename := filepath.Join(dir, "testdata", "file.golden")
+	assert.NilError(t, os.WriteFile(filename+".new", []byte("new"), 0o644))
+	other := filepath.Join(dir, "file.txt")
+	assert.NilError(t, os.WriteFile(other+".new", []byte("new"), 0o644))
+
+	err := run([]string{"-accept-all", dir}, strings.NewReader(""), new(bytes.Buffer))
+	assert.NilError(t, err)
+
+	content, err := os.ReadFile(filename)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "new")
+
+	_, err = os.Stat(other)
+	assert.Assert(t, os.IsNotExist(err), "expected %v to be ignored", other+".new")
+}
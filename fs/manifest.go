@@ -56,7 +56,10 @@ type dirEntry interface {
 // ManifestFromDir creates a [Manifest] by reading the directory at path. The
 // manifest stores the structure and properties of files in the directory.
 // ManifestFromDir can be used with [PathMatchesManifest] to compare two directories.
-func ManifestFromDir(t TestingT, path string) Manifest {
+//
+// The [PathOp] operations are applied to the root directory of the manifest,
+// and can be used to relax the expectations, for example with [MatchAnyFileModeInTree].
+func ManifestFromDir(t TestingT, path string, ops ...PathOp) Manifest {
 	if ht, ok := t.(helperT); ok {
 		ht.Helper()
 	}
@@ -65,6 +68,9 @@ func ManifestFromDir(t TestingT, path string) Manifest {
 	if err != nil {
 		t.Fatalf("failed to create manifest: %v", err)
 	}
+	if err := applyPathOps(&directoryPath{directory: manifest.root}, ops); err != nil {
+		t.Fatalf("failed to apply operations to manifest: %v", err)
+	}
 	return manifest
 }
 
@@ -74,6 +74,15 @@ func TestManifestFromDir(t *testing.T) {
 	actual.root.items["s"].(*directory).items["k"].(*file).content.Close()
 }
 
+func TestManifestFromDir_WithOps(t *testing.T) {
+	want := NewDir(t, "want", WithFile("a", "content a"))
+	got := NewDir(t, "got", WithFile("a", "content a"))
+	assert.NilError(t, os.Chmod(want.Path(), 0755))
+
+	manifest := ManifestFromDir(t, want.Path(), MatchAnyFileMode())
+	assert.NilError(t, PathMatchesManifest(got.Path(), manifest))
+}
+
 func TestSymlinks(t *testing.T) {
 	rootDirectory := NewDir(t, "root",
 		WithFile("foo.txt", "foo"),
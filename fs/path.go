@@ -195,3 +195,33 @@ func MatchAnyFileMode() PathOp {
 		return nil
 	}
 }
+
+// MatchAnyFileModeInTree is a [PathOp] that updates a [Manifest] so that the
+// directory at path, and all the files, symlinks, and directories it contains,
+// will match any file mode. Resources added to the manifest by later operations
+// are not changed.
+func MatchAnyFileModeInTree() DirOp {
+	return func(path Path) error {
+		if m, ok := path.(*directoryPath); ok {
+			setAnyFileMode(m.directory)
+		}
+		return nil
+	}
+}
+
+func setAnyFileMode(dir *directory) {
+	dir.mode = anyFileMode
+	for _, item := range dir.items {
+		switch item := item.(type) {
+		case *directory:
+			setAnyFileMode(item)
+		case *file:
+			item.mode = anyFileMode
+		case *symlink:
+			item.mode = anyFileMode
+		}
+	}
+	for _, glob := range dir.filepathGlobs {
+		glob.file.mode = anyFileMode
+	}
+}
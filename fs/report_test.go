@@ -186,6 +186,28 @@ func TestMatchAnyFileMode(t *testing.T) {
 	assert.Assert(t, PathMatchesManifest(dir.Path(), expected))
 }
 
+func TestMatchAnyFileModeInTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("expect mode does not match on windows")
+	}
+	want := NewDir(t, "want",
+		WithFile("data", "content", WithMode(0600)),
+		WithDir("sub", WithMode(0700),
+			WithFile("data", "content", WithMode(0600))))
+	defer want.Remove()
+	got := NewDir(t, "got",
+		WithFile("data", "content", WithMode(0644)),
+		WithDir("sub", WithMode(0755),
+			WithFile("data", "content", WithMode(0755))))
+	defer got.Remove()
+
+	manifest := ManifestFromDir(t, want.Path(), MatchAnyFileMode())
+	assert.Assert(t, PathMatchesManifest(got.Path(), manifest) != nil)
+
+	manifest = ManifestFromDir(t, want.Path(), MatchAnyFileModeInTree())
+	assert.Assert(t, PathMatchesManifest(got.Path(), manifest))
+}
+
 func TestMatchFileContent(t *testing.T) {
 	dir := NewDir(t, t.Name(),
 		WithFile("data", "content"))
@@ -0,0 +1,149 @@
+package golden
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// testCaller identifies the test that is comparing a golden file. It is used
+// to select the golden files to update, and to print a command that updates
+// the golden file.
+type testCaller struct {
+	// pkg is the package argument for go test.
+	pkg string
+	// name is the name of the test, in the format used by testing.T.Name.
+	name string
+	// run is the pattern for the -run flag that selects only this test. It
+	// is empty when the exact test is not known.
+	run string
+}
+
+// hint returns a message with the command that will update the golden file
+// identified by target. The command is on its own line so that it can be
+// copied into a shell.
+func (c testCaller) hint(target updateTarget) string {
+	return fmt.Sprintf("To update %s to the new value, run:\n\n\t%v", target.filename, c.command(target))
+}
+
+// createHint returns a message with the command that will create the golden
+// file identified by target.
+func (c testCaller) createHint(target updateTarget) string {
+	return fmt.Sprintf("To create %s, run this command locally, and commit the new file:\n\n\t%v",
+		target.filename, c.command(target))
+}
+
+// command returns the go test command that updates the golden file identified
+// by target.
+func (c testCaller) command(target updateTarget) string {
+	var run string
+	if c.run != "" {
+		run = " -run '" + c.run + "'"
+	}
+	return fmt.Sprintf("go test %v%v -update=%v", c.pkg, run, target.hash)
+}
+
+// callerFromStack returns the package and function name of the caller of
+// the exported function that called callerFromStack. When the exported
+// function is called by a helper in another package, the first caller in a
+// _test.go file is used instead. Function literals are reported using the name
+// of the function that contains them.
+func callerFromStack() testCaller {
+	name := callerFrame(4).Function // runtime.Callers + callerFrame + callerFromStack + exported function
+	slash := strings.LastIndex(name, "/") + 1
+	i := strings.Index(name[slash:], ".")
+	if i < 0 {
+		return testCaller{}
+	}
+	pkg, test := name[:slash+i], name[slash+i+1:]
+	if i := strings.Index(test, "."); i >= 0 {
+		test = test[:i]
+	}
+	return testCaller{pkg: pkg, name: test}
+}
+
+// callerFrame returns the first frame of the call stack after skipping skip
+// frames, or the first frame after that in a _test.go file if there is one.
+func callerFrame(skip int) runtime.Frame {
+	return testFrame(callerFrames(skip + 1))
+}
+
+// callerFrames returns the frames of the call stack after skipping skip
+// frames, up to and including the first frame in a _test.go file.
+func callerFrames(skip int) []runtime.Frame {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var result []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if frame.PC != 0 {
+			result = append(result, frame)
+		}
+		if !more || strings.HasSuffix(frame.File, "_test.go") {
+			return result
+		}
+	}
+}
+
+// testFrame returns the last frame if it is in a _test.go file, otherwise the
+// first frame.
+func testFrame(frames []runtime.Frame) runtime.Frame {
+	if len(frames) == 0 {
+		return runtime.Frame{}
+	}
+	if last := frames[len(frames)-1]; strings.HasSuffix(last.File, "_test.go") {
+		return last
+	}
+	return frames[0]
+}
+
+type namedT interface {
+	Name() string
+}
+
+// callerFromT returns the testCaller for the test t.
+func callerFromT(t namedT) testCaller {
+	name := t.Name()
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return testCaller{
+		pkg:  packageDir(),
+		name: name,
+		run:  strings.Join(parts, "/"),
+	}
+}
+
+// packageDir returns the relative path from the root of the module to the
+// package under test, in the form accepted by go test.
+var packageDir = sync.OnceValue(findPackageDir)
+
+// findPackageDir uses the working directory, which go test sets to the
+// directory of the package under test, to find the package relative to the
+// directory that contains the go.mod file.
+func findPackageDir() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for dir := wd; ; {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			rel, err := filepath.Rel(dir, wd)
+			if err != nil || rel == "." {
+				return "."
+			}
+			return "./" + filepath.ToSlash(rel)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "."
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,205 @@
+package golden
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dnephin/vt/fs"
+)
+
+// DirTestingT is the subset of [testing.T] used by [MatchDirToDir].
+type DirTestingT interface {
+	TestingT
+	fs.TestingT
+}
+
+// MatchDirToDir compares the directory gotDir to the golden directory wantDir,
+// and fails the test if the structure, file contents, or symlink targets are
+// different. The failure message contains a unified diff of each file
+// that does not match. File modes are not compared.
+//
+// Running `go test pkgname -update` replaces the contents of wantDir with a copy
+// of gotDir, adding, removing, and changing files as necessary. See
+// [MatchStringToFile] for the values accepted by -update. Directories can not be
+// updated using -update=review.
+func MatchDirToDir(t DirTestingT, gotDir string, wantDir string) {
+	t.Helper()
+	caller := callerFromT(t)
+	dirHash, err := hashDir(gotDir)
+	if err != nil {
+		t.Fatal(fmt.Errorf("read gotDir: %w", err))
+		return
+	}
+	target := updateTarget{hash: dirHash, filename: wantDir, testName: caller.name}
+
+	switch _, err := os.Stat(wantDir); {
+	case errors.Is(err, iofs.ErrNotExist):
+		err = createMissing(err, target, caller, func() error {
+			return updateDir(gotDir, target)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	case err != nil:
+		t.Fatal(fmt.Errorf("read wantDir: %w", err))
+		return
+	}
+
+	manifest := fs.ManifestFromDir(t, wantDir, fs.MatchAnyFileModeInTree())
+	failure := fs.PathMatchesManifest(gotDir, manifest)
+	switch {
+	case failure == nil:
+		report.add(target, outcomeUnchanged)
+	case requestUpdate(target):
+		if err := updateDir(gotDir, target); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatal(fmt.Errorf("%w\n%v", failure, caller.hint(target)))
+	}
+}
+
+// updateDir replaces the contents of the golden directory identified by
+// target with the contents of gotDir.
+func updateDir(gotDir string, target updateTarget) error {
+	wantDir := target.filename
+	if update.review() {
+		return fmt.Errorf("can not update directory %v: -update=review is not supported for directories", wantDir)
+	}
+
+	path, err := filepath.Abs(wantDir)
+	if err != nil {
+		return err
+	}
+	defer writes.lock(path)()
+	ok, err := recordWrite(path, target)
+	if err != nil || !ok {
+		return err
+	}
+
+	result := outcomeUpdated
+	if _, err := os.Stat(wantDir); errors.Is(err, iofs.ErrNotExist) {
+		result = outcomeCreated
+	}
+	if err := replaceDir(gotDir, wantDir); err != nil {
+		return fmt.Errorf("write wantDir: %w", err)
+	}
+	report.add(target, result)
+	return nil
+}
+
+// replaceDir replaces dest with a copy of source. The copy is written to a
+// temporary directory next to dest and renamed into place, so that an
+// interrupted update does not leave a partial copy at dest.
+func replaceDir(source, dest string) error {
+	parent, name := filepath.Split(filepath.Clean(dest))
+	if parent == "" {
+		parent = "."
+	}
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(parent, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // no-op after a successful rename
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+	if err := copyDir(source, tmp); err != nil {
+		return err
+	}
+
+	old := tmp + ".old"
+	switch err := os.Rename(dest, old); {
+	case errors.Is(err, iofs.ErrNotExist):
+		return os.Rename(tmp, dest)
+	case err != nil:
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.Rename(old, dest)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// hashDir returns a hash of the names, contents, and symlink targets of all the
+// files in the directory.
+func hashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%v %v\n", filepath.ToSlash(rel), d.Type())
+
+		switch {
+		case d.Type()&iofs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(h, target)
+		case d.Type().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return fmt.Sprintf("%x", h.Sum(nil))[:10], err
+}
+
+// copyDir copies the directory tree from source to dest. File modes and
+// symlinks are preserved.
+func copyDir(source, dest string) error {
+	return filepath.WalkDir(source, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case path == source:
+			return os.MkdirAll(target, 0o755)
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case d.Type()&iofs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,114 @@
+package golden
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dnephin/vt/fs"
+)
+
+func TestMatchDirToDir_Equal(t *testing.T) {
+	patch(t, &update, "no")
+	ops := []fs.PathOp{
+		fs.WithFile("a.txt", "content a\n"),
+		fs.WithDir("sub", fs.WithFile("b.txt", "content b\n")),
+	}
+	got := fs.NewDir(t, "got", ops...)
+	want := fs.NewDir(t, "want", ops...)
+
+	MatchDirToDir(t, got.Path(), want.Path())
+}
+
+func TestMatchDirToDir_IgnoresFileModes(t *testing.T) {
+	patch(t, &update, "")
+	got := fs.NewDir(t, "got",
+		fs.WithDir("sub", fs.WithMode(0o755),
+			fs.WithFile("b.txt", "content b\n", fs.WithMode(0o755))))
+	want := fs.NewDir(t, "want",
+		fs.WithDir("sub", fs.WithMode(0o700),
+			fs.WithFile("b.txt", "content b\n", fs.WithMode(0o600))))
+
+	MatchDirToDir(t, got.Path(), want.Path())
+}
+
+func TestMatchDirToDir_NotEqual(t *testing.T) {
+	patch(t, &update, "no")
+	got := fs.NewDir(t, "got",
+		fs.WithFile("a.txt", "content a\n"),
+		fs.WithFile("extra.txt", ""))
+	want := fs.NewDir(t, "want",
+		fs.WithFile("a.txt", "content b\n"),
+		fs.WithFile("missing.txt", ""))
+
+	ft := &fakeT{name: "TestDir"}
+	MatchDirToDir(ft, got.Path(), want.Path())
+	if len(ft.failed) != 1 {
+		t.Fatalf("MatchDirToDir(): expected test to fail")
+	}
+	msg := fmt.Sprint(ft.failed...)
+	for _, want := range []string{
+		"-content b\n",
+		"+content a\n",
+		"missing.txt: expected file to exist",
+		"extra.txt: unexpected file",
+		"\n\tgo test ./golden -run '^TestDir$' -update=",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("MatchDirToDir(): got\n%v\n, want\n%v\n", msg, want)
+		}
+	}
+}
+
+func TestMatchDirToDir_WithUpdate(t *testing.T) {
+	patch(t, &update, "yes")
+	got := fs.NewDir(t, "got",
+		fs.WithFile("a.txt", "new content\n"),
+		fs.WithDir("sub", fs.WithFile("b.txt", "content b\n")))
+	parent := t.TempDir()
+	want := fs.NewDir(t, "want",
+		fs.WithFile("a.txt", "old content\n"),
+		fs.WithFile("removed.txt", ""))
+	wantDir := filepath.Join(parent, "want")
+	if err := os.Rename(want.Path(), wantDir); err != nil {
+		t.Fatal(err)
+	}
+
+	MatchDirToDir(t, got.Path(), wantDir)
+	assertDirEntries(t, parent, "want")
+
+	patch(t, &update, "no")
+	MatchDirToDir(t, got.Path(), wantDir)
+}
+
+func TestMatchDirToDir_CreateWithUpdate(t *testing.T) {
+	t.Setenv("GOLDEN_STRICT", "0")
+	patch(t, &update, "yes")
+	got := fs.NewDir(t, "got", fs.WithFile("a.txt", "content\n"))
+	want := filepath.Join(t.TempDir(), "testdata", "expected-tree")
+
+	MatchDirToDir(t, got.Path(), want)
+	assertDirEntries(t, filepath.Dir(want), "expected-tree")
+
+	patch(t, &update, "no")
+	MatchDirToDir(t, got.Path(), want)
+}
+
+// assertDirEntries fails the test if dir contains anything other than names,
+// for example a temporary directory left behind by an update.
+func assertDirEntries(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(names) {
+		t.Fatalf("entries in %v: got %v, want %v", dir, got, names)
+	}
+}
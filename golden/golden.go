@@ -5,19 +5,28 @@ Golden files are files in the ./testdata/ subdirectory of the package under test
 Golden files can be automatically updated to match new values by running
 `go test pkgname -update`. To ensure the update is correct
 compare the diff of the old expected value to the new expected value.
+
+Running `go test pkgname -update=review` (or `GOLDEN_UPDATE=review go test
+pkgname -update`) writes the new values to pending files with a .new suffix
+next to the golden files, instead of replacing the golden files. The pending
+files in testdata directories can be accepted or rejected one at a time
+using the golden-review command:
+
+	go run github.com/dnephin/vt/cmd/golden-review ./...
+
+If another package, like gotest.tools, defines a bool -update flag before
+this package is initialized, that flag is used instead. It only accepts
+`-update`, so the hashes, patterns, or review mode must be set using the
+GOLDEN_UPDATE environment variable.
 */
 package golden
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
-	"path/filepath"
-	"runtime"
-	"strings"
 
 	"github.com/dnephin/vt/internal/format"
 )
@@ -27,54 +36,109 @@ import (
 // only changes will be highlighted using visible characters.
 //
 // Running `go test pkgname -update` will write the value of actual
-// to the golden file.
-func MatchStringToFile(got string, wantFilename string) error {
-	want, err := os.ReadFile(wantFilename)
+// to the golden file. The -update flag also accepts a comma separated list of
+// hashes, test name patterns, or filename globs to update only the selected
+// golden files. For example:
+//
+//	go test pkgname -update=abc123def4,TestSomething/case,testdata/*.golden
+//
+// MatchStringToFile does not have access to the testing.T, so the name of the
+// test is the name of the top-level test function found on the call stack.
+// A test name pattern with a subtest, like TestSomething/case, only selects
+// golden files compared using [Assert], which gets the name from T.Name.
+//
+// Options such as [WithReplace] and [TrimTrailingSpace] normalize got before
+// it is compared to the golden file, and before it is written to the golden
+// file on update.
+func MatchStringToFile(got string, wantFilename string, opts ...Option) error {
+	return matchString(got, wantFilename, newConfig(opts), callerFromStack())
+}
+
+// TestingT is the subset of [testing.T] used by [Assert].
+type TestingT interface {
+	Helper()
+	Name() string
+	Fatal(args ...interface{})
+}
+
+// Assert compares got to the contents of wantFilename, and fails the test with
+// a unified diff of the values if they are not equal. See [MatchStringToFile]
+// for details about the comparison, and how to update the golden file.
+//
+// Unlike MatchStringToFile, Assert uses t to print the exact command that will
+// re-run the test and update the golden file, so the command is correct even
+// when Assert is called from a test helper.
+func Assert(t TestingT, got string, wantFilename string, opts ...Option) {
+	t.Helper()
+	if err := matchString(got, wantFilename, newConfig(opts), callerFromT(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func matchString(got string, wantFilename string, conf config, caller testCaller) error {
+	got = conf.normalize(got, false)
+
+	raw, err := os.ReadFile(wantFilename)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) && update.Requested(hash(got)) {
-			return updateFile(got, wantFilename)
+		if errors.Is(err, fs.ErrNotExist) {
+			newValue := conf.newValue(got)
+			target := updateTarget{hash: hash(newValue), filename: wantFilename, testName: caller.name}
+			return createMissing(err, target, caller, func() error {
+				return updateFile(newValue, target)
+			})
 		}
 		return fmt.Errorf("read wantFilename: %w", err)
 	}
-	if bytes.Equal([]byte(got), want) {
+
+	result, err := conf.compare(got, conf.normalize(string(raw), true))
+	if err != nil {
+		return fmt.Errorf("golden file %v: %w", wantFilename, err)
+	}
+	if result.equal {
+		removePending(wantFilename)
+		report.add(updateTarget{filename: wantFilename, testName: caller.name}, outcomeUnchanged)
 		return nil
 	}
 
-	gotHash := hash(got)
-	if update.Requested(gotHash) {
-		return updateFile(got, wantFilename)
+	target := updateTarget{hash: hash(result.newValue), filename: wantFilename, testName: caller.name}
+	if requestUpdate(target) {
+		return updateFile(result.newValue, target)
 	}
 
 	diff := format.UnifiedDiff(format.DiffConfig{
 		A:    got,
-		B:    string(want),
+		B:    result.want,
 		From: "got",
 		To:   "want",
 	})
-	pkg, _ := currentTestName()
-	msg := "(-got +want):\n%v\nRun 'go test %v -update=%v' to update %s to the new value."
-	return fmt.Errorf(msg, diff, pkg, gotHash, wantFilename)
+	return fmt.Errorf("(-got +want):\n%v%v\n%v", diff, conf.describe(), caller.hint(target))
 }
 
-func hash(got string) string {
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(got)))[:10]
+// comparison is the result of comparing a value to a golden file.
+type comparison struct {
+	equal bool
+	// want is the expected value, used to show the diff.
+	want string
+	// newValue is the content written to the golden file on update.
+	newValue string
 }
 
-func updateFile(got string, wantFilename string) error {
-	if dir := filepath.Dir(wantFilename); dir != "." {
-		_ = os.MkdirAll(dir, 0o755)
-	}
-	if err := os.WriteFile(wantFilename, []byte(got), 0644); err != nil {
-		return fmt.Errorf("write wantfilename: %v", err)
+// compare got to the contents of the golden file.
+func (c config) compare(got, want string) (comparison, error) {
+	if c.template != nil {
+		return c.template.compare(got, want)
 	}
-	return nil
+	return comparison{equal: got == want, want: want, newValue: got}, nil
 }
 
-func currentTestName() (pkg string, test string) {
-	pc, _, _, _ := runtime.Caller(2) // currentTestName + MatchStringToFile
-	name := runtime.FuncForPC(pc).Name()
-	if i := strings.LastIndex(name, "."); i >= 0 {
-		return name[:i], name[i+1:]
+// newValue returns the content of a new golden file for got.
+func (c config) newValue(got string) string {
+	if c.template != nil {
+		return escapeTemplate(got)
 	}
-	return "", ""
+	return got
+}
+
+func hash(got string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(got)))[:10]
 }
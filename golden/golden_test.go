@@ -1,6 +1,7 @@
 package golden
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -25,6 +26,51 @@ func TestString_WithUpdate(t *testing.T) {
 
 }
 
+func TestString_WithUpdateSelectedByTestName(t *testing.T) {
+	patch(t, &update, "0000000000,^TestString_WithUpdateSelectedByTestName$")
+	filename := setupGoldenFile(t, "foo")
+
+	t.Run("subtest", func(t *testing.T) {
+		err := MatchStringToFile("new value", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "new value"; got != want {
+		t.Fatalf("MatchStringToFile(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
+func TestString_WithUpdateReview(t *testing.T) {
+	patch(t, &update, "review")
+	filename := setupGoldenFile(t, "foo")
+
+	err := MatchStringToFile("new value", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "foo"; got != want {
+		t.Fatalf("golden file: got=\n%v\n, want=\n%v\n", got, want)
+	}
+	content, err = os.ReadFile(filename + PendingSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "new value"; got != want {
+		t.Fatalf("pending file: got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
 func TestString_NotEqual(t *testing.T) {
 	patch(t, &update, "no")
 	filename := setupGoldenFile(t, "this is\nthe text")
@@ -71,3 +117,76 @@ func patch[T any](t *testing.T, dest *T, stub T) {
 		*dest = orig
 	})
 }
+
+type fakeT struct {
+	name   string
+	failed []interface{}
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Name() string {
+	return f.name
+}
+
+func (f *fakeT) Fatal(args ...interface{}) {
+	f.failed = args
+}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.failed = []interface{}{fmt.Sprintf(format, args...)}
+}
+
+func (f *fakeT) Log(...interface{}) {}
+
+func TestAssert_NotEqual(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, "the text")
+
+	ft := &fakeT{name: "TestSomething/sub_case"}
+	Assert(ft, "other text", filename)
+	if len(ft.failed) != 1 {
+		t.Fatalf("Assert(): expected test to fail")
+	}
+
+	want := "\n\tgo test ./golden -run '^TestSomething$/^sub_case$' -update=" + hash("other text")
+	if got := fmt.Sprint(ft.failed...); !strings.Contains(got, want) {
+		t.Fatalf("Assert(): got\n%v\n, want\n%v\n", got, want)
+	}
+}
+
+func TestAssert_WithUpdateSelectedByTestName(t *testing.T) {
+	patch(t, &update, "TestSomething/sub_case")
+	filename := setupGoldenFile(t, "the text")
+
+	ft := &fakeT{name: "TestSomething/sub_case"}
+	Assert(ft, "new text", filename)
+	if len(ft.failed) != 0 {
+		t.Fatalf("Assert(): unexpected failure: %v", ft.failed)
+	}
+}
+
+func TestAssert_WithUpdateSelectedBySubtest(t *testing.T) {
+	patch(t, &update, "^TestAssert_WithUpdateSelectedBySubtest$/^selected$")
+	selected := setupGoldenFile(t, "the text")
+	other := setupGoldenFile(t, "the text")
+
+	t.Run("selected", func(t *testing.T) {
+		Assert(t, "new text", selected)
+	})
+	t.Run("other", func(t *testing.T) {
+		if err := MatchStringToFile("new text", other); err == nil {
+			t.Fatal("MatchStringToFile(): expected an error, a subtest pattern should not select it")
+		}
+	})
+
+	for filename, want := range map[string]string{selected: "new text", other: "the text"} {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(content); got != want {
+			t.Fatalf("golden file %v: got %q, want %q", filename, got, want)
+		}
+	}
+}
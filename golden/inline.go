@@ -0,0 +1,305 @@
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	vtformat "github.com/dnephin/vt/internal/format"
+)
+
+// MatchInline compares got to want, and fails the test with a unified diff of
+// the values if they are not equal. MatchInline is used for short values that
+// would be tedious to store in a separate golden file.
+//
+// Running `go test pkgname -update` rewrites the want argument in the source file
+// of the test, so want must be a string literal:
+//
+//	golden.MatchInline(t, got, `expected value`)
+//
+// When MatchInline is called by a helper function, want may also be a parameter
+// of the helper. The argument for the parameter is updated in the call to the
+// helper, and must be a string literal, or a parameter of another helper.
+//
+// See [MatchStringToFile] for the values accepted by -update, and the options
+// that normalize got. Inline values can not be updated using -update=review.
+func MatchInline(t TestingT, got string, want string, opts ...Option) {
+	t.Helper()
+	frames := callerFrames(3) // runtime.Callers + callerFrames + MatchInline
+	if len(frames) == 0 {
+		t.Fatal("failed to get call stack")
+		return
+	}
+	frame := testFrame(frames)
+	conf := newConfig(opts)
+	caller := callerFromT(t)
+	got = conf.normalize(got, false)
+	target := updateTarget{
+		hash:     hash(got),
+		filename: fmt.Sprintf("%v:%d", frame.File, frame.Line),
+		testName: caller.name,
+	}
+	if got == conf.normalize(want, true) {
+		report.add(target, outcomeUnchanged)
+		return
+	}
+	if requestUpdate(target) {
+		if err := updateInline(frames, got, target); err != nil {
+			t.Fatal(err)
+			return
+		}
+		report.add(target, outcomeUpdated)
+		return
+	}
+
+	diff := vtformat.UnifiedDiff(vtformat.DiffConfig{
+		A:    got,
+		B:    want,
+		From: "got",
+		To:   "want",
+	})
+	t.Fatal(fmt.Errorf("(-got +want):\n%v%v\n%v", diff, conf.describe(), caller.hint(target)))
+}
+
+// inlineEdits records the number of lines added to each source file by
+// updateInline. The line numbers reported by runtime.Caller are from the
+// source file when the test binary was compiled, so the line number of a call
+// must be adjusted by the number of lines added or removed before it.
+var inlineEdits = struct {
+	sync.Mutex
+	files map[string][]inlineEdit
+}{files: make(map[string][]inlineEdit)}
+
+type inlineEdit struct {
+	// line is the line number of the call in the original source file.
+	line int
+	// delta is the number of lines added to the file by the edit.
+	delta int
+}
+
+func adjustLine(path string, line int) int {
+	inlineEdits.Lock()
+	defer inlineEdits.Unlock()
+	adjusted := line
+	for _, edit := range inlineEdits.files[path] {
+		if edit.line < line {
+			adjusted += edit.delta
+		}
+	}
+	return adjusted
+}
+
+func recordInlineEdit(path string, edit inlineEdit) {
+	inlineEdits.Lock()
+	defer inlineEdits.Unlock()
+	inlineEdits.files[path] = append(inlineEdits.files[path], edit)
+}
+
+// goldenImportPath is the import path of this package, used to find calls to
+// MatchInline in source files.
+const goldenImportPath = "github.com/dnephin/vt/golden"
+
+// inlineCall identifies the call that passes the want argument. It starts as
+// the call to MatchInline, and becomes the call to a helper function when the
+// argument is a parameter of the helper.
+type inlineCall struct {
+	// isFunc returns true if fun is the function being called in file.
+	isFunc func(file *ast.File, fun ast.Expr) bool
+	// arg is the index of the want argument.
+	arg int
+	// name is used in error messages.
+	name string
+}
+
+// updateInline replaces the want argument of the call to MatchInline with got.
+// frames are the frames of the call stack, starting with the caller of
+// MatchInline. If the want argument is a parameter of the calling function,
+// the argument of the call to that function in the next frame is replaced.
+func updateInline(frames []runtime.Frame, got string, target updateTarget) error {
+	if update.review() {
+		return errors.New("can not update inline value: -update=review is not supported by MatchInline")
+	}
+	call := inlineCall{isFunc: isMatchInline, arg: 2, name: "MatchInline"}
+	for _, frame := range frames {
+		next, done, err := updateInlineFrame(frame, call, got, target)
+		if err != nil || done {
+			return err
+		}
+		call = next
+	}
+	return fmt.Errorf("failed to update inline value: %v is called with a parameter", call.name)
+}
+
+// updateInlineFrame replaces the want argument of call at the location of
+// frame with got, and returns done=true. If the argument is a parameter of the
+// function in frame, it returns the call to that function instead.
+func updateInlineFrame(frame runtime.Frame, call inlineCall, got string, target updateTarget) (inlineCall, bool, error) {
+	path, err := filepath.Abs(frame.File)
+	if err != nil {
+		return inlineCall{}, false, err
+	}
+	defer writes.lock(path)()
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return inlineCall{}, false, fmt.Errorf("failed to read Go source file: %w", err)
+	}
+	fileset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fileset, path, src, parser.ParseComments)
+	if err != nil {
+		return inlineCall{}, false, fmt.Errorf("failed to parse Go source file: %w", err)
+	}
+
+	adjusted := adjustLine(path, frame.Line)
+	expr, err := findInlineArg(fileset, astFile, adjusted, call)
+	if err != nil {
+		return inlineCall{}, false, fmt.Errorf("failed to update %v:%d: %w", frame.File, adjusted, err)
+	}
+	switch arg := expr.(type) {
+	case *ast.BasicLit:
+		if arg.Kind == token.STRING {
+			return inlineCall{}, true, replaceInlineLiteral(path, frame.Line, src, fileset, arg, got, target)
+		}
+	case *ast.Ident:
+		if next, ok := paramCall(astFile, arg, frame.Function); ok {
+			return next, false, nil
+		}
+	}
+	return inlineCall{}, false, fmt.Errorf("failed to update %v:%d: the want argument to %v must be "+
+		"a string literal, or a parameter of the calling function", frame.File, adjusted, call.name)
+}
+
+// replaceInlineLiteral replaces lit in the source file at path with got. Only
+// the literal is replaced, so that the rest of the file is unchanged even if it
+// is not formatted.
+func replaceInlineLiteral(path string, line int, src []byte, fileset *token.FileSet, lit *ast.BasicLit, got string, target updateTarget) error {
+	ok, err := recordWrite(fmt.Sprintf("%v:%d", path, line), target)
+	if err != nil || !ok {
+		return err
+	}
+
+	start, end := fileset.Position(lit.Pos()).Offset, fileset.Position(lit.End()).Offset
+	quoted := quoteInline(got)
+	edited := make([]byte, 0, len(src)+len(quoted))
+	edited = append(edited, src[:start]...)
+	edited = append(edited, quoted...)
+	edited = append(edited, src[end:]...)
+
+	if err := writeFileAtomic(path, edited); err != nil {
+		return fmt.Errorf("failed to write Go source file: %w", err)
+	}
+
+	delta := strings.Count(quoted, "\n") - strings.Count(lit.Value, "\n")
+	recordInlineEdit(path, inlineEdit{line: line, delta: delta})
+	return nil
+}
+
+// findInlineArg returns the want argument of call, on the line of file.
+func findInlineArg(fileset *token.FileSet, file *ast.File, line int, call inlineCall) (ast.Expr, error) {
+	var found *ast.CallExpr
+	ast.Inspect(file, func(node ast.Node) bool {
+		if node == nil || found != nil {
+			return false
+		}
+		if fileset.Position(node.Pos()).Line > line || fileset.Position(node.End()).Line < line {
+			return false
+		}
+		if ce, ok := node.(*ast.CallExpr); ok && call.isFunc(file, ce.Fun) {
+			found = ce
+			return false
+		}
+		return true
+	})
+
+	switch {
+	case found == nil:
+		return nil, fmt.Errorf("failed to find call to %v", call.name)
+	case len(found.Args) <= call.arg:
+		return nil, fmt.Errorf("wrong number of arguments to %v", call.name)
+	}
+	return found.Args[call.arg], nil
+}
+
+// isMatchInline returns true if fun is MatchInline from this package. The
+// package must be imported using its import path, unless file is part of this
+// package.
+func isMatchInline(file *ast.File, fun ast.Expr) bool {
+	switch typed := fun.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := typed.X.(*ast.Ident)
+		return ok && typed.Sel.Name == "MatchInline" && importName(file, goldenImportPath) == pkg.Name
+	case *ast.Ident:
+		return typed.Name == "MatchInline" && file.Name.Name == "golden" && typed.Obj == nil
+	}
+	return false
+}
+
+// importName returns the name used to refer to the package with importPath in
+// file, or an empty string if the package is not imported.
+func importName(file *ast.File, importPath string) string {
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err != nil || path != importPath {
+			continue
+		}
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return filepath.Base(importPath)
+	}
+	return ""
+}
+
+// paramCall returns the call to the function named by function, when ident is
+// one of its parameters. function is the name of the function from
+// runtime.Frame, which is used to find the function in file.
+func paramCall(file *ast.File, ident *ast.Ident, function string) (inlineCall, bool) {
+	name := function[strings.LastIndex(function, ".")+1:]
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != name || ident.Obj == nil {
+			continue
+		}
+		index := 0
+		for _, field := range fn.Type.Params.List {
+			for _, param := range field.Names {
+				if param.Name == ident.Name && ident.Obj.Decl == field {
+					return inlineCall{isFunc: isFuncNamed(name), arg: index, name: name}, true
+				}
+				index++
+			}
+		}
+	}
+	return inlineCall{}, false
+}
+
+// isFuncNamed returns a function that matches a call to a function or method
+// with name. The call is already identified by the line in the call stack, so
+// the name is sufficient to find it.
+func isFuncNamed(name string) func(*ast.File, ast.Expr) bool {
+	return func(_ *ast.File, fun ast.Expr) bool {
+		switch typed := fun.(type) {
+		case *ast.SelectorExpr:
+			return typed.Sel.Name == name
+		case *ast.Ident:
+			return typed.Name == name
+		}
+		return false
+	}
+}
+
+// quoteInline returns s as a Go string literal. A raw string literal is used
+// when possible.
+func quoteInline(s string) string {
+	if !strconv.CanBackquote(strings.ReplaceAll(s, "\n", "")) {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
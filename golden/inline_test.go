@@ -0,0 +1,152 @@
+package golden
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMatchInline(t *testing.T) {
+	patch(t, &update, "no")
+	MatchInline(t, "the value\n", `the value
+`)
+}
+
+func TestMatchInline_NotEqual(t *testing.T) {
+	patch(t, &update, "no")
+	ft := &fakeT{name: "TestInline"}
+	MatchInline(ft, "other value", `the value`)
+	if len(ft.failed) != 1 {
+		t.Fatalf("MatchInline(): expected test to fail")
+	}
+	want := "-other value\n+the value\n"
+	if got := fmt.Sprint(ft.failed...); !strings.Contains(got, want) {
+		t.Fatalf("MatchInline(): got\n%v\n, want\n%v\n", got, want)
+	}
+}
+
+const inlineFixture = `package example
+
+import "github.com/dnephin/vt/golden"
+
+func TestExample(t *testing.T) {
+	golden.MatchInline(t, first(), "")
+
+	golden.MatchInline(t, second(),
+		` + "`old\nvalue`" + `)
+	// a comment
+	var  unformatted = 1
+}
+`
+
+func TestUpdateInline(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := filepath.Join(t.TempDir(), "example_test.go")
+	if err := os.WriteFile(filename, []byte(inlineFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := updateTarget{testName: "TestExample"}
+	frames := []runtime.Frame{{File: filename, Line: 6, Function: "example.TestExample"}}
+	if err := updateInline(frames, "one\ntwo\nthree", target); err != nil {
+		t.Fatal(err)
+	}
+	// line 9 is the line of the second call in the original file
+	frames = []runtime.Frame{{File: filename, Line: 9, Function: "example.TestExample"}}
+	if err := updateInline(frames, "has a ` backquote", target); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `package example
+
+import "github.com/dnephin/vt/golden"
+
+func TestExample(t *testing.T) {
+	golden.MatchInline(t, first(), ` + "`one\ntwo\nthree`" + `)
+
+	golden.MatchInline(t, second(),
+		"has a ` + "`" + ` backquote")
+	// a comment
+	var  unformatted = 1
+}
+`
+	if got := string(content); got != want {
+		t.Fatalf("updateInline(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
+const inlineHelperFixture = `package example
+
+import (
+	"testing"
+
+	vtgolden "github.com/dnephin/vt/golden"
+)
+
+func TestExample(t *testing.T) {
+	check(t, "value", "")
+}
+
+func check(t *testing.T, got, want string) {
+	vtgolden.MatchInline(t, got, want)
+}
+`
+
+func TestUpdateInline_Helper(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := filepath.Join(t.TempDir(), "example_test.go")
+	if err := os.WriteFile(filename, []byte(inlineHelperFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := []runtime.Frame{
+		{File: filename, Line: 14, Function: "example.check"},
+		{File: filename, Line: 10, Function: "example.TestExample"},
+	}
+	if err := updateInline(frames, "new value", updateTarget{testName: "TestExample"}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(inlineHelperFixture, `check(t, "value", "")`, "check(t, \"value\", `new value`)", 1)
+	if got := string(content); got != want {
+		t.Fatalf("updateInline(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
+const inlineOtherPackageFixture = `package example
+
+import (
+	"testing"
+
+	"example.com/golden"
+)
+
+func TestExample(t *testing.T) {
+	golden.MatchInline(t, "value", "")
+}
+`
+
+func TestUpdateInline_OtherPackage(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := filepath.Join(t.TempDir(), "example_test.go")
+	if err := os.WriteFile(filename, []byte(inlineOtherPackageFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := []runtime.Frame{{File: filename, Line: 10, Function: "example.TestExample"}}
+	err := updateInline(frames, "new value", updateTarget{testName: "TestExample"})
+	if err == nil || !strings.Contains(err.Error(), "failed to find call to MatchInline") {
+		t.Fatalf("updateInline(): expected error, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package golden
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Option changes how a value is compared to a golden file. See
+// [MatchStringToFile].
+type Option func(*config)
+
+type config struct {
+	normalizers []normalizer
+	template    *templateConfig
+}
+
+func newConfig(opts []Option) config {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// normalizer modifies the value before it is compared to the golden file.
+type normalizer struct {
+	// description is included in the failure message
+	description string
+	apply       func(string) string
+	// golden is true if the normalizer should also be applied to the contents
+	// of the golden file.
+	golden bool
+}
+
+// normalize applies all the normalizers to the value. If golden is true only
+// the normalizers that apply to the contents of the golden file are used.
+func (c config) normalize(value string, golden bool) string {
+	for _, n := range c.normalizers {
+		if golden && !n.golden {
+			continue
+		}
+		value = n.apply(value)
+	}
+	return value
+}
+
+// describe returns a message listing the normalizers that were applied.
+func (c config) describe() string {
+	if len(c.normalizers) == 0 {
+		return ""
+	}
+	buf := new(strings.Builder)
+	buf.WriteString("\nThe value was normalized before comparison:\n")
+	for _, n := range c.normalizers {
+		buf.WriteString("  - " + n.description + "\n")
+	}
+	return buf.String()
+}
+
+// WithReplace is an [Option] that replaces all matches of re in the value with
+// placeholder. The placeholder may reference submatches using the syntax
+// accepted by [regexp.Regexp.ReplaceAllString].
+//
+// WithReplace can be used to replace values that change on every run, like
+// timestamps, UUIDs, or durations, with a stable placeholder.
+func WithReplace(re *regexp.Regexp, placeholder string) Option {
+	return func(c *config) {
+		c.normalizers = append(c.normalizers, normalizer{
+			description: fmt.Sprintf("replaced matches of %q with %q", re, placeholder),
+			apply: func(s string) string {
+				return re.ReplaceAllString(s, placeholder)
+			},
+		})
+	}
+}
+
+// TempDirPlaceholder is the value used by [WithTempDir] to replace the path
+// to a temporary directory.
+const TempDirPlaceholder = "[TEMPDIR]"
+
+// WithTempDir is an [Option] that replaces all occurrences of the path dir in
+// the value with [TempDirPlaceholder]. The path with symlinks resolved, and
+// the path using forward slashes, are also replaced.
+func WithTempDir(dir string) Option {
+	paths := []string{dir}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil && resolved != dir {
+		paths = append(paths, resolved)
+	}
+	if slashed := filepath.ToSlash(dir); slashed != dir {
+		paths = append(paths, slashed)
+	}
+	// Replace the longest path first, because dir may be a suffix of the
+	// resolved path, ex: /var/folders and /private/var/folders on macOS.
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+	return func(c *config) {
+		c.normalizers = append(c.normalizers, normalizer{
+			description: fmt.Sprintf("replaced %v with %v", dir, TempDirPlaceholder),
+			apply: func(s string) string {
+				for _, path := range paths {
+					s = strings.ReplaceAll(s, path, TempDirPlaceholder)
+				}
+				return s
+			},
+		})
+	}
+}
+
+// IgnoreLineEndings is an [Option] that replaces all \r\n line endings with \n.
+// Unlike other options, IgnoreLineEndings is also applied to the contents
+// of the golden file.
+var IgnoreLineEndings Option = func(c *config) {
+	c.normalizers = append(c.normalizers, normalizer{
+		description: `replaced \r\n line endings with \n`,
+		apply: func(s string) string {
+			return strings.ReplaceAll(s, "\r\n", "\n")
+		},
+		golden: true,
+	})
+}
+
+// TrimTrailingSpace is an [Option] that removes whitespace from the end of
+// every line.
+var TrimTrailingSpace Option = func(c *config) {
+	c.normalizers = append(c.normalizers, normalizer{
+		description: "removed trailing whitespace from every line",
+		apply:       trimTrailingSpace,
+	})
+}
+
+func trimTrailingSpace(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		var eol string
+		switch {
+		case strings.HasSuffix(line, "\r\n"):
+			eol = "\r\n"
+		case strings.HasSuffix(line, "\n"):
+			eol = "\n"
+		}
+		lines[i] = strings.TrimRight(strings.TrimSuffix(line, eol), " \t\v\f\r") + eol
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,98 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestString_WithOptions(t *testing.T) {
+	patch(t, &update, "no")
+	tmpDir := t.TempDir()
+	filename := setupGoldenFile(t, "wrote [TEMPDIR]/out.txt in [DURATION]\r\nline two\r\n")
+
+	got := "wrote " + filepath.Join(tmpDir, "out.txt") + " in 1.23s   \nline two\n"
+	err := MatchStringToFile(got, filename,
+		WithTempDir(tmpDir),
+		WithReplace(regexp.MustCompile(`[0-9.]+s\b`), "[DURATION]"),
+		TrimTrailingSpace,
+		IgnoreLineEndings)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestString_WithOptionsNotEqual(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, "took [DURATION]\n")
+
+	err := MatchStringToFile("took 3 minutes\n", filename,
+		WithReplace(regexp.MustCompile(`[0-9.]+s\b`), "[DURATION]"))
+	if err == nil {
+		t.Fatal("MatchStringToFile(): expected an error, got nil")
+	}
+	want := `The value was normalized before comparison:
+  - replaced matches of "[0-9.]+s\\b" with "[DURATION]"
+`
+	if got := err.Error(); !strings.Contains(got, want) {
+		t.Fatalf("MatchStringToFile(): got\n%v\n, want\n%v\n", got, want)
+	}
+}
+
+func TestString_WithOptionsAndUpdate(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := setupGoldenFile(t, "")
+
+	err := MatchStringToFile("id: 1234-abcd  \n", filename,
+		WithReplace(regexp.MustCompile(`[0-9]{4}-[a-z]{4}`), "[ID]"),
+		TrimTrailingSpace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "id: [ID]\n"; got != want {
+		t.Fatalf("MatchStringToFile(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
+func TestTrimTrailingSpace(t *testing.T) {
+	got := trimTrailingSpace("one \t\ntwo  \r\n  three  ")
+	if want := "one\ntwo\r\n  three"; got != want {
+		t.Fatalf("trimTrailingSpace(): got %q, want %q", got, want)
+	}
+}
+
+func TestWithTempDir_SymlinkSuffix(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "private", "var", "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("private", "var"), filepath.Join(root, "var")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+
+	// the resolved path, private/var/dir, ends with the path var/dir
+	dir := filepath.Join("var", "dir")
+	conf := newConfig([]Option{WithTempDir(dir)})
+	got := conf.normalize(filepath.Join("private", "var", "dir", "file")+" "+filepath.Join(dir, "file"), false)
+	want := filepath.Join(TempDirPlaceholder, "file") + " " + filepath.Join(TempDirPlaceholder, "file")
+	if got != want {
+		t.Fatalf("normalize(): got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+package golden
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// outcome is the result of comparing a value to a golden file.
+type outcome string
+
+const (
+	outcomeCreated   outcome = "created"
+	outcomeUpdated   outcome = "updated"
+	outcomePending   outcome = "pending"
+	outcomeUnchanged outcome = "unchanged"
+	outcomeMismatch  outcome = "mismatch"
+	outcomeRefused   outcome = "refused"
+	outcomeConflict  outcome = "conflict"
+)
+
+// outcomeOrder is the order of outcomes in the report.
+var outcomeOrder = []outcome{
+	outcomeCreated,
+	outcomeUpdated,
+	outcomePending,
+	outcomeRefused,
+	outcomeConflict,
+	outcomeMismatch,
+	outcomeUnchanged,
+}
+
+type reportEntry struct {
+	outcome  outcome
+	filename string
+	testName string
+}
+
+// report records the outcome of every comparison to a golden file made by the
+// test binary.
+var report = reportRecorder{entries: make(map[reportEntry]bool)}
+
+type reportRecorder struct {
+	mu      sync.Mutex
+	enabled bool
+	entries map[reportEntry]bool
+}
+
+// add records the outcome for target. Outcomes are only recorded when the
+// report is enabled by Main.
+func (r *reportRecorder) add(target updateTarget, o outcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.enabled {
+		return
+	}
+	r.entries[reportEntry{outcome: o, filename: target.filename, testName: target.testName}] = true
+}
+
+func (r *reportRecorder) isEnabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabled
+}
+
+// requestUpdate returns true if the golden file identified by target should
+// be updated. If the file will not be updated the outcome is recorded in the
+// report.
+func requestUpdate(target updateTarget) bool {
+	if update.Requested(target) {
+		return true
+	}
+	if update == "" {
+		report.add(target, outcomeMismatch)
+		return false
+	}
+
+	report.add(target, outcomeRefused)
+	if !report.isEnabled() {
+		fmt.Printf("Refusing to update %v because -update=%v did not match the hash %v or test %v\n",
+			target.filename, update, target.hash, target.testName)
+	}
+	return false
+}
+
+// M is the subset of [testing.M] used by [Main].
+type M interface {
+	Run() int
+}
+
+// Main runs the tests using m, and then prints a summary of every golden file
+// that was created, updated, unchanged, had conflicting updates, or that did
+// not match. Main returns the exit code for the test binary. Main should be
+// called from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(golden.Main(m))
+//	}
+//
+// When GOLDEN_STRICT=1 is set, Main also fails if any file with a .golden
+// extension in ./testdata/ was not used by a test. Running in CI does not
+// enable the check, because a golden file used only by a skipped test would
+// fail it. The check is skipped when -short, -run, or -skip are used.
+//
+// When Main is used the refusals to update a golden file because of a
+// mismatched -update value are only printed in the summary.
+//
+// If the GOLDEN_REPORT environment variable is set, the summary is written to
+// the file named by the variable instead of stdout. Each line of the file
+// contains the outcome, the golden file, and the name of the test, separated by
+// tabs. A CI job can use the file to fail if any golden file would change.
+func Main(m M) int {
+	report.mu.Lock()
+	report.enabled = true
+	report.mu.Unlock()
+
+	code := m.Run()
+	if err := writeReport(); err != nil {
+		fmt.Fprintf(os.Stderr, "golden: failed to write report: %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	if code == 0 {
+		if err := checkUnused(); err != nil {
+			fmt.Fprintf(os.Stderr, "golden: %v\n", err)
+			code = 1
+		}
+	}
+	return code
+}
+
+func writeReport() error {
+	filename := os.Getenv("GOLDEN_REPORT")
+	if filename == "" {
+		printReport(os.Stdout)
+		return nil
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writeReportEntries(f)
+	return f.Close()
+}
+
+// printReport prints a human readable summary of the report. Unchanged golden
+// files are only included in the count.
+func printReport(out io.Writer) {
+	entries := sortedReportEntries()
+	if len(entries) == 0 {
+		return
+	}
+
+	counts := make(map[outcome]int)
+	for _, entry := range entries {
+		counts[entry.outcome]++
+	}
+	var summary []string
+	for _, o := range outcomeOrder {
+		if counts[o] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %v", counts[o], o))
+		}
+	}
+	fmt.Fprintf(out, "golden files: %v\n", strings.Join(summary, ", "))
+	for _, entry := range entries {
+		if entry.outcome == outcomeUnchanged {
+			continue
+		}
+		fmt.Fprintf(out, "  %-9v %v (%v)\n", entry.outcome, entry.filename, entry.testName)
+	}
+}
+
+// writeReportEntries writes every entry in the report using a tab separated
+// format.
+func writeReportEntries(out io.Writer) {
+	for _, entry := range sortedReportEntries() {
+		fmt.Fprintf(out, "%v\t%v\t%v\n", entry.outcome, entry.filename, entry.testName)
+	}
+}
+
+func sortedReportEntries() []reportEntry {
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	order := make(map[outcome]int, len(outcomeOrder))
+	for i, o := range outcomeOrder {
+		order[o] = i
+	}
+	entries := make([]reportEntry, 0, len(report.entries))
+	for entry := range report.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		x, y := entries[i], entries[j]
+		switch {
+		case x.outcome != y.outcome:
+			return order[x.outcome] < order[y.outcome]
+		case x.filename != y.filename:
+			return x.filename < y.filename
+		}
+		return x.testName < y.testName
+	})
+	return entries
+}
@@ -0,0 +1,93 @@
+package golden
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeM struct {
+	run func()
+}
+
+func (m fakeM) Run() int {
+	m.run()
+	return 0
+}
+
+func TestMain_WithReport(t *testing.T) {
+	t.Setenv("GOLDEN_STRICT", "0")
+	patch(t, &report.entries, make(map[reportEntry]bool))
+	patch(t, &report.enabled, false)
+	reportFile := filepath.Join(t.TempDir(), "report.txt")
+	t.Setenv("GOLDEN_REPORT", reportFile)
+
+	dir := t.TempDir()
+	unchanged := filepath.Join(dir, "unchanged.golden")
+	if err := os.WriteFile(unchanged, []byte("same"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(dir, "changed.golden")
+	if err := os.WriteFile(changed, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code := Main(fakeM{run: func() {
+		patch(t, &update, "yes")
+		_ = MatchStringToFile("same", unchanged)
+		_ = MatchStringToFile("new", changed)
+		_ = MatchStringToFile("created", filepath.Join(dir, "created.golden"))
+
+		patch(t, &update, "0000000000")
+		_ = MatchStringToFile("other", changed)
+
+		patch(t, &update, "")
+		_ = MatchStringToFile("mismatch", unchanged)
+	}})
+	if code != 0 {
+		t.Fatalf("Main(): got exit code %d", code)
+	}
+
+	content, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "created\t" + filepath.Join(dir, "created.golden") + "\tTestMain_WithReport\n" +
+		"updated\t" + changed + "\tTestMain_WithReport\n" +
+		"refused\t" + changed + "\tTestMain_WithReport\n" +
+		"mismatch\t" + unchanged + "\tTestMain_WithReport\n" +
+		"unchanged\t" + unchanged + "\tTestMain_WithReport\n"
+	if got := string(content); got != want {
+		t.Fatalf("report: got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
+func TestReport_NotEnabled(t *testing.T) {
+	patch(t, &report.entries, make(map[reportEntry]bool))
+	patch(t, &report.enabled, false)
+
+	report.add(updateTarget{filename: "testdata/a.golden", testName: "TestA"}, outcomeUnchanged)
+	if len(report.entries) != 0 {
+		t.Fatalf("report: expected no entries, got %v", report.entries)
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	patch(t, &report.entries, map[reportEntry]bool{
+		{outcome: outcomeUnchanged, filename: "testdata/a.golden", testName: "TestA"}: true,
+		{outcome: outcomeUnchanged, filename: "testdata/b.golden", testName: "TestB"}: true,
+		{outcome: outcomeRefused, filename: "testdata/c.golden", testName: "TestC"}:   true,
+		{outcome: outcomeCreated, filename: "testdata/d.golden", testName: "TestD"}:   true,
+	})
+
+	out := new(bytes.Buffer)
+	printReport(out)
+	want := `golden files: 1 created, 1 refused, 2 unchanged
+  created   testdata/d.golden (TestD)
+  refused   testdata/c.golden (TestC)
+`
+	if got := out.String(); got != want {
+		t.Fatalf("printReport(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
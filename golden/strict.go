@@ -0,0 +1,139 @@
+package golden
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// strictMode returns true if golden files must not be created by -update.
+// Strict mode is enabled by setting GOLDEN_STRICT=1, or by running in CI, where
+// the CI environment variable is set. GOLDEN_STRICT=0 disables strict mode in CI.
+func strictMode() bool {
+	if enabled, ok := strictFromEnv(); ok {
+		return enabled
+	}
+	switch strings.ToLower(os.Getenv("CI")) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+// strictFromEnv returns the value of GOLDEN_STRICT, and ok=false if the
+// variable is not set to a recognized value.
+func strictFromEnv() (enabled bool, ok bool) {
+	switch strings.ToLower(os.Getenv("GOLDEN_STRICT")) {
+	case "1", "true", "yes":
+		return true, true
+	case "0", "false", "no":
+		return false, true
+	}
+	return false, false
+}
+
+// createMissing calls create to create the golden file that does not exist if
+// an update was requested, otherwise returns an error that explains how to
+// create the golden file. In strict mode the golden file is never created.
+func createMissing(err error, target updateTarget, caller testCaller, create func() error) error {
+	if strictMode() {
+		report.add(target, outcomeRefused)
+		return fmt.Errorf("refusing to create golden file %v because strict mode is enabled "+
+			"by GOLDEN_STRICT or CI: %w\n%v", target.filename, err, caller.createHint(target))
+	}
+	if requestUpdate(target) {
+		return create()
+	}
+	return fmt.Errorf("golden file %v does not exist: %w\n%v",
+		target.filename, err, caller.createHint(target))
+}
+
+// unusedGoldenFiles returns the files with a .golden extension in the testdata
+// directory that were not compared by any test.
+func unusedGoldenFiles() ([]string, error) {
+	used := make(map[string]bool)
+	var usedDirs []string
+	for _, entry := range sortedReportEntries() {
+		path, err := filepath.Abs(entry.filename)
+		if err != nil {
+			continue
+		}
+		used[path] = true
+		usedDirs = append(usedDirs, path+string(filepath.Separator))
+	}
+
+	var unused []string
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() || filepath.Ext(path) != ".golden":
+			return nil
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if !used[abs] && !hasAnyPrefix(abs, usedDirs) {
+			unused = append(unused, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	sort.Strings(unused)
+	return unused, err
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUnused returns an error if GOLDEN_STRICT=1 is set and any golden files
+// were not used. Golden files used only by skipped tests look unused, so
+// running in CI does not enable the check. The check is skipped when -short,
+// -run, or -skip may skip some of the tests.
+func checkUnused() error {
+	if enabled, _ := strictFromEnv(); !enabled {
+		return nil
+	}
+	if isPartialRun() {
+		return nil
+	}
+	unused, err := unusedGoldenFiles()
+	switch {
+	case err != nil:
+		return err
+	case len(unused) == 0:
+		return nil
+	}
+	return fmt.Errorf("GOLDEN_STRICT is set, and these golden files were not used by any test:\n  %v\n"+
+		"Remove the files, or unset GOLDEN_STRICT to disable the check.",
+		strings.Join(unused, "\n  "))
+}
+
+// isPartialRun returns true if -short, -run, or -skip were used. The flags are
+// read from the flag package, so that the package does not import testing in
+// non-test code.
+func isPartialRun() bool {
+	return flagValue("test.short") == "true" || flagValue("test.run") != "" || flagValue("test.skip") != ""
+}
+
+// flagValue returns the value of the flag with name, or an empty string if the
+// flag is not defined.
+func flagValue(name string) string {
+	if f := flag.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
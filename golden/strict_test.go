@@ -0,0 +1,145 @@
+package golden
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestString_MissingFile(t *testing.T) {
+	t.Setenv("GOLDEN_STRICT", "0")
+	patch(t, &update, "")
+	filename := filepath.Join(t.TempDir(), "missing.golden")
+
+	err := MatchStringToFile("value", filename)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("MatchStringToFile(): expected not exist error, got %v", err)
+	}
+	want := "golden file " + filename + " does not exist"
+	if got := err.Error(); !strings.Contains(got, want) || !strings.Contains(got, "run this command locally") {
+		t.Fatalf("MatchStringToFile(): got\n%v\n, want\n%v\n", got, want)
+	}
+}
+
+func TestString_MissingFileStrict(t *testing.T) {
+	t.Setenv("GOLDEN_STRICT", "")
+	t.Setenv("CI", "true")
+	patch(t, &update, "yes")
+	filename := filepath.Join(t.TempDir(), "missing.golden")
+
+	err := MatchStringToFile("value", filename)
+	if err == nil {
+		t.Fatal("MatchStringToFile(): expected an error, got nil")
+	}
+	want := "refusing to create golden file " + filename + " because strict mode is enabled"
+	if got := err.Error(); !strings.Contains(got, want) {
+		t.Fatalf("MatchStringToFile(): got\n%v\n, want\n%v\n", got, want)
+	}
+	if _, err := os.Stat(filename); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected golden file to not exist, got %v", err)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	for _, tc := range []struct {
+		strict string
+		ci     string
+		want   bool
+	}{
+		{want: false},
+		{strict: "1", want: true},
+		{ci: "true", want: true},
+		{ci: "false", want: false},
+		{strict: "0", ci: "true", want: false},
+	} {
+		t.Setenv("GOLDEN_STRICT", tc.strict)
+		t.Setenv("CI", tc.ci)
+		if got := strictMode(); got != tc.want {
+			t.Fatalf("strictMode(GOLDEN_STRICT=%q, CI=%q): got %v, want %v", tc.strict, tc.ci, got, tc.want)
+		}
+	}
+}
+
+func TestUnusedGoldenFiles(t *testing.T) {
+	patch(t, &report.entries, map[reportEntry]bool{
+		{outcome: outcomeUnchanged, filename: "testdata/used.golden"}: true,
+		{outcome: outcomeUnchanged, filename: "testdata/tree"}:        true,
+	})
+	chdirWithGoldenFiles(t, "used.golden", "unused.golden", "other.txt", "tree/in-dir.golden")
+
+	unused, err := unusedGoldenFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join("testdata", "unused.golden")
+	if len(unused) != 1 || unused[0] != want {
+		t.Fatalf("unusedGoldenFiles(): got %v, want [%v]", unused, want)
+	}
+}
+
+func TestCheckUnused(t *testing.T) {
+	if isPartialRun() {
+		t.Skip("checkUnused is skipped by -short, -run, and -skip")
+	}
+	patch(t, &report.entries, map[reportEntry]bool{})
+	chdirWithGoldenFiles(t, "unused.golden")
+
+	t.Setenv("GOLDEN_STRICT", "")
+	t.Setenv("CI", "true")
+	if err := checkUnused(); err != nil {
+		t.Fatalf("checkUnused() in CI: expected no error, got %v", err)
+	}
+
+	t.Setenv("GOLDEN_STRICT", "1")
+	err := checkUnused()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join("testdata", "unused.golden")) {
+		t.Fatalf("checkUnused() with GOLDEN_STRICT=1: expected unused file error, got %v", err)
+	}
+}
+
+func TestCheckUnused_Short(t *testing.T) {
+	patch(t, &report.entries, map[reportEntry]bool{})
+	chdirWithGoldenFiles(t, "unused.golden")
+	t.Setenv("GOLDEN_STRICT", "1")
+
+	short := flag.Lookup("test.short")
+	orig := short.Value.String()
+	if err := short.Value.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = short.Value.Set(orig) })
+
+	if err := checkUnused(); err != nil {
+		t.Fatalf("checkUnused() with -short: expected no error, got %v", err)
+	}
+}
+
+// chdirWithGoldenFiles changes the working directory to a new directory with
+// a testdata directory that contains empty files with names.
+func chdirWithGoldenFiles(t *testing.T, names ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, "testdata", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+}
@@ -0,0 +1,285 @@
+package golden
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// WithTemplate is an [Option] that parses the golden file as a [text/template]
+// before it is compared to the value. The template is executed with data, so
+// values that change between runs can be passed in by the test:
+//
+//	listening on port {{ .Port }}
+//
+// The template may also use the regexp function to match any value that
+// matches a regular expression:
+//
+//	commit {{ regexp "[0-9a-f]{40}" }}
+//
+// Running `go test pkgname -update` preserves the template actions on every line
+// that still matches, and only replaces the lines that changed. An action that
+// spans multiple lines, like {{ range }} ... {{ end }}, is preserved if all of
+// its lines still match. New lines are written as literal text, with any {{
+// escaped.
+//
+// WithTemplate is used by [MatchStringToFile] and [Assert], and is ignored by
+// the other functions in this package.
+func WithTemplate(data any) Option {
+	return func(c *config) {
+		c.template = &templateConfig{data: data}
+	}
+}
+
+type templateConfig struct {
+	data any
+}
+
+// regexpMarker is the value returned by the regexp template function when
+// building a pattern. It is replaced by the pattern at index %d.
+const regexpMarker = "\x00golden-regexp-%d\x00"
+
+var regexpMarkerPattern = regexp.MustCompile("\x00golden-regexp-([0-9]+)\x00")
+
+// pattern executes the template src and returns a regular expression that
+// matches the rendered template.
+func (c *templateConfig) pattern(src string) (*regexp.Regexp, error) {
+	var patterns []string
+	funcs := template.FuncMap{
+		"regexp": func(pattern string) (string, error) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return "", err
+			}
+			patterns = append(patterns, pattern)
+			return fmt.Sprintf(regexpMarker, len(patterns)-1), nil
+		},
+	}
+	rendered, err := c.execute(src, funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := new(strings.Builder)
+	expr.WriteString(`\A`)
+	last := 0
+	for _, match := range regexpMarkerPattern.FindAllStringSubmatchIndex(rendered, -1) {
+		expr.WriteString(regexp.QuoteMeta(rendered[last:match[0]]))
+		index, _ := strconv.Atoi(rendered[match[2]:match[3]])
+		expr.WriteString("(?:" + patterns[index] + ")")
+		last = match[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(rendered[last:]))
+	expr.WriteString(`\z`)
+	return regexp.Compile(expr.String())
+}
+
+// display executes the template src, and returns the rendered template with
+// each regexp function call shown as the call, instead of the pattern.
+func (c *templateConfig) display(src string) (string, error) {
+	funcs := template.FuncMap{
+		"regexp": func(pattern string) string {
+			return fmt.Sprintf("{{ regexp %q }}", pattern)
+		},
+	}
+	return c.execute(src, funcs)
+}
+
+func (c *templateConfig) execute(src string, funcs template.FuncMap) (string, error) {
+	tmpl, err := template.New("golden").Funcs(funcs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return "", err
+	}
+	buf := new(strings.Builder)
+	if err := tmpl.Execute(buf, c.data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// compare got to the golden template src. The template is first matched
+// against all of got. If that fails the blocks of the template are aligned
+// with the lines of got, to create a want value that only shows the lines that
+// changed, and a newValue that preserves the template actions of blocks that
+// did not change.
+func (c *templateConfig) compare(got, src string) (comparison, error) {
+	re, err := c.pattern(src)
+	if err != nil {
+		return comparison{}, fmt.Errorf("invalid template: %w", err)
+	}
+	if re.MatchString(got) {
+		return comparison{equal: true}, nil
+	}
+
+	blocks := c.splitBlocks(src)
+	gotLines := splitLines(got)
+	var want, newValue strings.Builder
+	for _, op := range alignBlocks(blocks, gotLines) {
+		switch {
+		case op.src >= 0 && op.got >= 0:
+			segment := strings.Join(gotLines[op.got:op.got+op.lines], "")
+			want.WriteString(segment)
+			newValue.WriteString(blocks[op.src].src)
+		case op.src >= 0:
+			want.WriteString(blocks[op.src].display)
+		default:
+			newValue.WriteString(escapeTemplate(gotLines[op.got]))
+		}
+	}
+	return comparison{want: want.String(), newValue: newValue.String()}, nil
+}
+
+// splitLines splits s after each newline. Unlike strings.SplitAfter the
+// result does not contain an empty string when s ends with a newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// templateBlock is a line of a template, or all the lines of a template action
+// that spans multiple lines, like {{ range }} ... {{ end }}. A block that can
+// not be rendered never matches.
+type templateBlock struct {
+	src     string
+	pattern *regexp.Regexp
+	display string
+	// anyLines is true if the block may render to any number of lines.
+	anyLines bool
+}
+
+// splitBlocks splits the template src into blocks that can each be parsed as
+// a template. An action that trims the whitespace of an adjacent line, like
+// {{- end }}, is in the same block as that line. If the end of src is reached
+// before an action is complete, the remaining lines are each used as a block.
+func (c *templateConfig) splitBlocks(src string) []templateBlock {
+	var blocks []templateBlock
+	var pending []string
+	for _, line := range splitLines(src) {
+		if len(pending) == 0 && len(blocks) > 0 && trimsAdjacent(blocks[len(blocks)-1].src, line) {
+			pending = splitLines(blocks[len(blocks)-1].src)
+			blocks = blocks[:len(blocks)-1]
+		}
+		pending = append(pending, line)
+		if block := strings.Join(pending, ""); c.parses(block) {
+			blocks = append(blocks, c.newTemplateBlock(block, len(pending)))
+			pending = nil
+		}
+	}
+	for _, line := range pending {
+		blocks = append(blocks, c.newTemplateBlock(line, 1))
+	}
+	return blocks
+}
+
+// trimsAdjacent returns true if an action at the end of prev, or the start of
+// line, trims the newline between them.
+func trimsAdjacent(prev, line string) bool {
+	return strings.HasSuffix(strings.TrimRight(prev, " \t\r\n"), "-}}") ||
+		strings.HasPrefix(strings.TrimLeft(line, " \t"), "{{-")
+}
+
+func (c *templateConfig) parses(src string) bool {
+	funcs := template.FuncMap{"regexp": func(string) string { return "" }}
+	_, err := template.New("golden").Funcs(funcs).Parse(src)
+	return err == nil
+}
+
+func (c *templateConfig) newTemplateBlock(src string, lines int) templateBlock {
+	block := templateBlock{src: src, display: src, anyLines: lines > 1 || strings.Contains(src, "{{")}
+	re, err := c.pattern(src)
+	if err != nil {
+		return block
+	}
+	block.pattern = re
+	if display, err := c.display(src); err == nil {
+		block.display = display
+	}
+	return block
+}
+
+func (b templateBlock) match(got string) bool {
+	return b.pattern != nil && b.pattern.MatchString(got)
+}
+
+// blockOp is a block from the template, a line from got, or a block that
+// matches lines of got. The index of a missing block or line is -1.
+type blockOp struct {
+	src int
+	got int
+	// lines is the number of lines of got matched by the block.
+	lines int
+}
+
+// skipSrc and skipGot are the choices in alignBlocks that do not match a
+// block. Any other choice is the number of lines matched by the block.
+const (
+	skipSrc = -2
+	skipGot = -1
+)
+
+// alignBlocks returns the longest common subsequence of blocks from the
+// template that match lines of got, along with the unmatched blocks and
+// lines, in order. A block with an action may match any number of lines.
+func alignBlocks(blocks []templateBlock, gotLines []string) []blockOp {
+	n, m := len(blocks), len(gotLines)
+	offsets := make([]int, m+1)
+	for j, line := range gotLines {
+		offsets[j+1] = offsets[j] + len(line)
+	}
+	got := strings.Join(gotLines, "")
+
+	lengths := make([][]int, n+1)
+	choices := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+		choices[i] = make([]int, m+1)
+		for j := range choices[i] {
+			choices[i][j] = skipGot
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			best, choice := lengths[i+1][j], skipSrc
+			if j < m && lengths[i][j+1] > best {
+				best, choice = lengths[i][j+1], skipGot
+			}
+			minLines, maxLines := 1, 1
+			if blocks[i].anyLines {
+				minLines, maxLines = 0, m-j
+			}
+			for k := minLines; k <= maxLines && j+k <= m; k++ {
+				if lengths[i+1][j+k]+1 > best && blocks[i].match(got[offsets[j]:offsets[j+k]]) {
+					best, choice = lengths[i+1][j+k]+1, k
+				}
+			}
+			lengths[i][j], choices[i][j] = best, choice
+		}
+	}
+
+	var ops []blockOp
+	for i, j := 0, 0; i < n || j < m; {
+		switch choice := choices[i][j]; {
+		case choice == skipSrc:
+			ops = append(ops, blockOp{src: i, got: -1})
+			i++
+		case choice == skipGot:
+			ops = append(ops, blockOp{src: -1, got: j})
+			j++
+		default:
+			ops = append(ops, blockOp{src: i, got: j, lines: choice})
+			i++
+			j += choice
+		}
+	}
+	return ops
+}
+
+// escapeTemplate escapes the template delimiters in s, so that the template
+// renders s.
+func escapeTemplate(s string) string {
+	return strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+}
@@ -0,0 +1,135 @@
+package golden
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const templateFixture = `server version {{ .Version }}
+listening on port {{ .Port }}
+commit {{ regexp "[0-9a-f]{8}" }}
+done
+`
+
+type templateData struct {
+	Version string
+	Port    int
+}
+
+func TestString_WithTemplate(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, templateFixture)
+
+	got := "server version 1.2.3\nlistening on port 8080\ncommit 0123abcd\ndone\n"
+	err := MatchStringToFile(got, filename, WithTemplate(templateData{Version: "1.2.3", Port: 8080}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestString_WithTemplateNotEqual(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, templateFixture)
+
+	got := "server version 1.2.3\nlistening on port 8080\ncommit not-a-hash\ndone\n"
+	err := MatchStringToFile(got, filename, WithTemplate(templateData{Version: "1.2.3", Port: 8080}))
+	if err == nil {
+		t.Fatal("MatchStringToFile(): expected an error, got nil")
+	}
+	want := `
+ server version 1.2.3
+ listening on port 8080
+-commit not-a-hash
++commit {{ regexp "[0-9a-f]{8}" }}
+ done
+`
+	if got := err.Error(); !strings.Contains(got, want) {
+		t.Fatalf("MatchStringToFile(): got\n%v\n, want\n%v\n", got, want)
+	}
+}
+
+func TestString_WithTemplateInvalid(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, "{{ .Missing }}")
+
+	err := MatchStringToFile("", filename, WithTemplate(templateData{}))
+	if err == nil {
+		t.Fatal("MatchStringToFile(): expected an error, got nil")
+	}
+	if got, want := err.Error(), "invalid template"; !strings.Contains(got, want) {
+		t.Fatalf("MatchStringToFile(): got %v, want %v", got, want)
+	}
+}
+
+func TestString_WithTemplateAndUpdate(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := setupGoldenFile(t, templateFixture)
+
+	got := "server version 1.2.3\nlistening on port 9090\ncommit 0123abcd\nnew {{ line }}\ndone\n"
+	err := MatchStringToFile(got, filename, WithTemplate(templateData{Version: "1.2.3", Port: 8080}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `server version {{ .Version }}
+listening on port 9090
+commit {{ regexp "[0-9a-f]{8}" }}
+new {{"{{"}} line }}
+done
+`
+	if got := string(content); got != want {
+		t.Fatalf("MatchStringToFile(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+
+	patch(t, &update, "no")
+	err = MatchStringToFile(got, filename, WithTemplate(templateData{Version: "1.2.3", Port: 8080}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestString_WithTemplateMultilineActionAndUpdate(t *testing.T) {
+	patch(t, &update, "yes")
+	src := `items:
+{{- range .Items }}
+  - {{ . }}
+{{- end }}
+version {{ .Version }}
+done
+`
+	filename := setupGoldenFile(t, src)
+	data := struct {
+		Items   []string
+		Version string
+	}{Items: []string{"one", "two"}, Version: "1.2.3"}
+
+	got := "items:\n  - one\n  - two\nversion 1.2.3\nfinished\n"
+	if err := MatchStringToFile(got, filename, WithTemplate(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `items:
+{{- range .Items }}
+  - {{ . }}
+{{- end }}
+version {{ .Version }}
+finished
+`
+	if got := string(content); got != want {
+		t.Fatalf("MatchStringToFile(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+
+	patch(t, &update, "no")
+	if err := MatchStringToFile(got, filename, WithTemplate(data)); err != nil {
+		t.Fatal(err)
+	}
+}
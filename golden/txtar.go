@@ -0,0 +1,184 @@
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/dnephin/vt/internal/format"
+)
+
+// Section is a named part of a multi-section golden file.
+type Section struct {
+	Name    string
+	Content string
+}
+
+// MatchSectionsToFile compares each section in got to the section with the
+// same name in the golden file wantFilename. The golden file uses the txtar
+// archive format: an optional comment, followed by sections that each start
+// with a marker line of the form
+//
+//	-- name --
+//
+// Each section is compared independently, and the error contains a unified diff
+// for every section that does not match. Like txtar, the content of every
+// section must end with a newline. A newline is added to content that does
+// not end with one.
+//
+// Running `go test pkgname -update` rewrites only the sections that changed,
+// removes sections that are not in got, and appends new sections to the end of
+// the file. The comment at the start of the file is preserved. See
+// [MatchStringToFile] for the other values accepted by -update, and the
+// options that normalize the content of each section.
+func MatchSectionsToFile(got []Section, wantFilename string, opts ...Option) error {
+	caller := callerFromStack()
+	conf := newConfig(opts)
+	normalized := make([]Section, 0, len(got))
+	names := make(map[string]bool, len(got))
+	for _, section := range got {
+		if names[section.Name] {
+			return fmt.Errorf("got has more than one section named %q", section.Name)
+		}
+		names[section.Name] = true
+		content := fixNewline(conf.normalize(section.Content, false))
+		normalized = append(normalized, Section{Name: section.Name, Content: content})
+	}
+	got = normalized
+
+	raw, err := os.ReadFile(wantFilename)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		newValue := archive{sections: got}.format()
+		target := updateTarget{hash: hash(newValue), filename: wantFilename, testName: caller.name}
+		return createMissing(err, target, caller, func() error {
+			return updateFile(newValue, target)
+		})
+	case err != nil:
+		return fmt.Errorf("read wantFilename: %w", err)
+	}
+
+	want := parseArchive(string(raw))
+	diffs, updated := compareSections(got, want, conf)
+	if len(diffs) == 0 {
+		removePending(wantFilename)
+		report.add(updateTarget{filename: wantFilename, testName: caller.name}, outcomeUnchanged)
+		return nil
+	}
+
+	newValue := updated.format()
+	target := updateTarget{hash: hash(newValue), filename: wantFilename, testName: caller.name}
+	if requestUpdate(target) {
+		return updateFile(newValue, target)
+	}
+	return fmt.Errorf("%v%v\n%v", strings.Join(diffs, "\n"), conf.describe(), caller.hint(target))
+}
+
+// compareSections returns a diff for each section that does not match, and
+// a copy of want updated to match got.
+func compareSections(got []Section, want archive, conf config) ([]string, archive) {
+	var diffs []string
+	updated := archive{comment: want.comment}
+
+	gotByName := make(map[string]string, len(got))
+	for _, section := range got {
+		gotByName[section.Name] = section.Content
+	}
+	wantNames := make(map[string]bool, len(want.sections))
+	for _, section := range want.sections {
+		wantNames[section.Name] = true
+
+		content, ok := gotByName[section.Name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("section %q is not in got", section.Name))
+			continue
+		}
+		normalized := conf.normalize(section.Content, true)
+		if content == normalized {
+			updated.sections = append(updated.sections, section)
+			continue
+		}
+		diffs = append(diffs, diffSection(section.Name, content, normalized))
+		updated.sections = append(updated.sections, Section{Name: section.Name, Content: content})
+	}
+
+	for _, section := range got {
+		if wantNames[section.Name] {
+			continue
+		}
+		diffs = append(diffs, diffSection(section.Name, section.Content, ""))
+		updated.sections = append(updated.sections, section)
+	}
+	return diffs, updated
+}
+
+func diffSection(name string, got, want string) string {
+	diff := format.UnifiedDiff(format.DiffConfig{
+		A:    got,
+		B:    want,
+		From: "got",
+		To:   "want",
+	})
+	if want == "" {
+		return fmt.Sprintf("section %q is not in the golden file (-got +want):\n%v", name, diff)
+	}
+	return fmt.Sprintf("section %q (-got +want):\n%v", name, diff)
+}
+
+// archive is a txtar archive.
+type archive struct {
+	comment  string
+	sections []Section
+}
+
+// parseArchive parses the txtar archive in data. Any text before the first
+// section marker is the comment.
+func parseArchive(data string) archive {
+	var a archive
+	var current *Section
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if name, ok := sectionMarker(line); ok {
+			a.sections = append(a.sections, Section{Name: name})
+			current = &a.sections[len(a.sections)-1]
+			continue
+		}
+		if current == nil {
+			a.comment += line
+			continue
+		}
+		current.Content += line
+	}
+	return a
+}
+
+// sectionMarker returns the name of the section if line is a section marker.
+func sectionMarker(line string) (string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "-- ") || !strings.HasSuffix(line, " --") || len(line) < 6 {
+		return "", false
+	}
+	name := strings.TrimSpace(line[3 : len(line)-3])
+	return name, name != ""
+}
+
+// format returns the archive in the txtar format.
+func (a archive) format() string {
+	buf := new(strings.Builder)
+	buf.WriteString(fixNewline(a.comment))
+	for _, section := range a.sections {
+		fmt.Fprintf(buf, "-- %s --\n", section.Name)
+		buf.WriteString(fixNewline(section.Content))
+	}
+	return buf.String()
+}
+
+// fixNewline adds a newline to the end of s if it is not empty and does not
+// already end with a newline.
+func fixNewline(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
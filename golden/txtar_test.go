@@ -0,0 +1,137 @@
+package golden
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const archiveFixture = `This is the comment.
+It spans two lines.
+-- stdout --
+line one
+line two
+-- stderr --
+warning
+-- removed --
+gone
+`
+
+func TestSections_Equal(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, archiveFixture)
+
+	err := MatchSectionsToFile([]Section{
+		{Name: "stderr", Content: "warning"},
+		{Name: "stdout", Content: "line one\nline two\n"},
+		{Name: "removed", Content: "gone\n"},
+	}, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSections_NotEqual(t *testing.T) {
+	patch(t, &update, "no")
+	filename := setupGoldenFile(t, archiveFixture)
+
+	err := MatchSectionsToFile([]Section{
+		{Name: "stdout", Content: "line one\nline 2\n"},
+		{Name: "stderr", Content: "warning\n"},
+		{Name: "files", Content: "a.txt\n"},
+	}, filename)
+	if err == nil {
+		t.Fatal("MatchSectionsToFile(): expected an error, got nil")
+	}
+
+	for _, want := range []string{
+		"section \"stdout\" (-got +want):\n--- got\n+++ want\n@@ -1,3 +1,3 @@\n line one\n-line 2\n+line two\n",
+		"section \"removed\" is not in got\n",
+		"section \"files\" is not in the golden file (-got +want):\n",
+	} {
+		if got := err.Error(); !strings.Contains(got, want) {
+			t.Fatalf("MatchSectionsToFile(): got\n%v\n, want\n%v\n", got, want)
+		}
+	}
+	if got := err.Error(); strings.Contains(got, `section "stderr"`) {
+		t.Fatalf("MatchSectionsToFile(): unexpected diff for stderr\n%v", got)
+	}
+}
+
+func TestSections_DuplicateName(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := setupGoldenFile(t, archiveFixture)
+
+	err := MatchSectionsToFile([]Section{
+		{Name: "stdout", Content: "one\n"},
+		{Name: "stdout", Content: "two\n"},
+	}, filename)
+	if err == nil || !strings.Contains(err.Error(), `more than one section named "stdout"`) {
+		t.Fatalf("MatchSectionsToFile(): expected duplicate section error, got %v", err)
+	}
+}
+
+func TestSections_EqualRemovesPending(t *testing.T) {
+	patch(t, &update, "review")
+	filename := setupGoldenFile(t, archiveFixture)
+	if err := os.WriteFile(filename+PendingSuffix, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := MatchSectionsToFile([]Section{
+		{Name: "stdout", Content: "line one\nline two\n"},
+		{Name: "stderr", Content: "warning\n"},
+		{Name: "removed", Content: "gone\n"},
+	}, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename + PendingSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected pending file to be removed, got %v", err)
+	}
+}
+
+func TestSections_WithUpdate(t *testing.T) {
+	patch(t, &update, "yes")
+	filename := setupGoldenFile(t, archiveFixture)
+
+	err := MatchSectionsToFile([]Section{
+		{Name: "stdout", Content: "line one\nline 2\n"},
+		{Name: "stderr", Content: "warning\n"},
+		{Name: "files", Content: "a.txt"},
+	}, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `This is the comment.
+It spans two lines.
+-- stdout --
+line one
+line 2
+-- stderr --
+warning
+-- files --
+a.txt
+`
+	if got := string(content); got != want {
+		t.Fatalf("MatchSectionsToFile(): got=\n%v\n, want=\n%v\n", got, want)
+	}
+}
+
+func TestParseArchive_RoundTrip(t *testing.T) {
+	a := parseArchive(archiveFixture)
+	if got, want := len(a.sections), 3; got != want {
+		t.Fatalf("parseArchive(): got %d sections, want %d", got, want)
+	}
+	if got, want := a.comment, "This is the comment.\nIt spans two lines.\n"; got != want {
+		t.Fatalf("parseArchive(): got comment %q, want %q", got, want)
+	}
+	if got := a.format(); got != archiveFixture {
+		t.Fatalf("format(): got=\n%v\n, want=\n%v\n", got, archiveFixture)
+	}
+}
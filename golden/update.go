@@ -2,16 +2,64 @@ package golden
 
 import (
 	"flag"
-	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 )
 
 var update updateValue
 
+// sharedUpdate is the bool -update flag defined by another package, like
+// gotest.tools, that was initialized before this package. The flag belongs to
+// the other package, so it is not replaced, and its value is only read.
+var sharedUpdate struct {
+	once sync.Once
+	flag flag.Getter
+}
+
 func init() {
+	if f := flag.Lookup("update"); f != nil {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			panic("golden: another package defined an incompatible -update flag, expected a flag.Bool")
+		}
+		if _, ok := getter.Get().(bool); !ok {
+			panic("golden: another package defined an incompatible -update flag, expected a flag.Bool")
+		}
+		sharedUpdate.flag = getter
+		return
+	}
 	flag.Var(&update, "update", "update golden files")
 }
 
+// readSharedUpdate sets update from the -update flag defined by another
+// package. The flag is read once, after the flags are parsed by the first
+// comparison.
+func readSharedUpdate() {
+	sharedUpdate.once.Do(func() {
+		if sharedUpdate.flag != nil {
+			update = sharedUpdateValue(sharedUpdate.flag)
+		}
+	})
+}
+
+// sharedUpdateValue returns the value of update for a bool -update flag.
+// Because the flag only accepts a bool, the hashes, patterns, or review mode
+// must be set using GOLDEN_UPDATE.
+func sharedUpdateValue(f flag.Getter) updateValue {
+	var u updateValue
+	if set, _ := f.Get().(bool); set {
+		_ = u.Set("true")
+	}
+	return u
+}
+
+// updateValue is the value of the -update flag. The value is either "yes",
+// to update all golden files, "review" to write all changes to pending files,
+// or a comma separated list of patterns which select the golden files to update.
+// See [updateValue.Requested].
 type updateValue string
 
 func (u *updateValue) IsBoolFlag() bool {
@@ -34,29 +82,142 @@ func (u *updateValue) Set(v string) error {
 		// used internally for testing
 	case "always", "yes", "force":
 		*u = "yes"
+	case "review":
+		*u = "review"
 	default:
 		*u = updateValue(v)
 	}
 	return nil
 }
 
-func (u *updateValue) Requested(gotHash string) bool {
+// updateTarget identifies the golden file that would be updated.
+type updateTarget struct {
+	// hash is the hash of the new value, as printed in failure messages.
+	hash string
+	// filename is the path to the golden file.
+	filename string
+	// testName is the name of the test that is comparing the golden file. When
+	// the comparison has access to a testing.T the name is from T.Name, and
+	// includes the names of subtests. Otherwise it is the name of the top-level
+	// test function found on the call stack.
+	testName string
+}
+
+// Requested returns true if the golden file identified by target should be
+// updated. Each comma separated pattern in the flag value is compared to
+// target, and the file is updated if any of the patterns match:
+//
+//   - the hash of the new value;
+//   - the filename of the golden file, using [filepath.Match] glob syntax;
+//   - the name of the test, as a regular expression using the same syntax as
+//     the -run flag.
+func (u *updateValue) Requested(target updateTarget) bool {
+	readSharedUpdate()
 	if u == nil || *u == "" {
 		return false
 	}
-	if *u == "yes" {
+	if *u == "yes" || *u == "review" {
 		return true
 	}
-	if string(*u) == gotHash {
+	for _, pattern := range strings.Split(string(*u), ",") {
+		if matchUpdatePattern(strings.TrimSpace(pattern), target) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchUpdatePattern(pattern string, target updateTarget) bool {
+	switch {
+	case pattern == "":
+		return false
+	case pattern == target.hash:
+		return true
+	case target.filename != "" && matchFilename(pattern, target.filename):
+		return true
+	case target.testName != "" && matchTestName(pattern, target.testName):
 		return true
 	}
+	return false
+}
 
-	fmt.Printf("Refusing to update because the hash %v did not match the actual value hash %v\n", *u, gotHash)
+func matchFilename(pattern string, filename string) bool {
+	pattern, filename = filepath.FromSlash(pattern), filepath.Clean(filename)
+	if ok, _ := filepath.Match(pattern, filename); ok {
+		return true
+	}
+	// a pattern without a path separator also matches the base name
+	if !strings.ContainsRune(pattern, filepath.Separator) {
+		ok, _ := filepath.Match(pattern, filepath.Base(filename))
+		return ok
+	}
 	return false
 }
 
+// matchTestName matches testName against pattern in the same way as the -run
+// flag. The pattern is split on unbracketed slashes, and each element must match
+// the corresponding element of the test name.
+func matchTestName(pattern string, testName string) bool {
+	patterns := splitRegexp(pattern)
+	names := strings.Split(testName, "/")
+	if len(patterns) > len(names) {
+		return false
+	}
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil || !re.MatchString(names[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitRegexp splits a pattern on the slashes that are not inside brackets or
+// parentheses. Copied from the testing package.
+func splitRegexp(s string) []string {
+	a := make([]string, 0, strings.Count(s, "/"))
+	cs := 0
+	cp := 0
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '[':
+			cs++
+		case ']':
+			if cs--; cs < 0 { // An unmatched ']' is legal.
+				cs = 0
+			}
+		case '(':
+			if cs == 0 {
+				cp++
+			}
+		case ')':
+			if cs == 0 {
+				cp--
+			}
+		case '\\':
+			i++
+		case '/':
+			if cs == 0 && cp == 0 {
+				a = append(a, s[:i])
+				s = s[i+1:]
+				i = 0
+				continue
+			}
+		}
+		i++
+	}
+	return append(a, s)
+}
+
+// review returns true if changes should be written to pending files for
+// review, instead of replacing the golden file.
+func (u *updateValue) review() bool {
+	readSharedUpdate()
+	return u != nil && *u == "review"
+}
+
 // Get provides compatibility with other libraries that define
 // an optional bool flag for -update.
 func (u *updateValue) Get() any {
-	return u.Requested("")
+	return u != nil && *u == "yes"
 }
@@ -0,0 +1,78 @@
+package golden
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestUpdateValue_Requested(t *testing.T) {
+	target := updateTarget{
+		hash:     "abc123def4",
+		filename: "testdata/some-file.golden",
+		testName: "TestSomething/with_case",
+	}
+
+	type testCase struct {
+		name  string
+		value updateValue
+		want  bool
+	}
+	testCases := []testCase{
+		{name: "empty", value: "", want: false},
+		{name: "yes", value: "yes", want: true},
+		{name: "matching hash", value: "abc123def4", want: true},
+		{name: "other hash", value: "0000000000", want: false},
+		{name: "list with matching hash", value: "0000000000,abc123def4", want: true},
+		{name: "test name", value: "TestSomething", want: true},
+		{name: "anchored test name", value: "^TestSomething$/^with_case$", want: true},
+		{name: "other subtest", value: "TestSomething/other", want: false},
+		{name: "test name prefix", value: "TestSome", want: true},
+		{name: "other test", value: "^TestOther$", want: false},
+		{name: "filename glob", value: "testdata/*.golden", want: true},
+		{name: "basename glob", value: "some-*", want: true},
+		{name: "other glob", value: "other/*.golden", want: false},
+		{name: "list of mixed", value: "0000000000, testdata/*.golden", want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.value.Requested(target); got != tc.want {
+				t.Fatalf("Requested(): got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdateValue_Set(t *testing.T) {
+	t.Setenv("GOLDEN_UPDATE", "")
+
+	var u updateValue
+	for _, v := range []string{"true", "yes", "always", "force"} {
+		if err := u.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u != "yes" {
+		t.Fatalf("Set(): got %v, want yes", u)
+	}
+
+	if err := u.Set("abc,TestFoo"); err != nil {
+		t.Fatal(err)
+	}
+	if u != "abc,TestFoo" {
+		t.Fatalf("Set(): got %v, want abc,TestFoo", u)
+	}
+}
+
+func TestSharedUpdateValue(t *testing.T) {
+	t.Setenv("GOLDEN_UPDATE", "review")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	shared := fs.Bool("update", false, "")
+
+	if got := sharedUpdateValue(fs.Lookup("update").Value.(flag.Getter)); got != "" {
+		t.Fatalf("sharedUpdateValue(false): got %q, want empty", got)
+	}
+	*shared = true
+	if got := sharedUpdateValue(fs.Lookup("update").Value.(flag.Getter)); got != "review" {
+		t.Fatalf("sharedUpdateValue(true): got %q, want review", got)
+	}
+}
@@ -0,0 +1,151 @@
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// PendingSuffix is appended to the filename of a golden file to create the
+// filename of the pending file written by `go test -update=review`.
+const PendingSuffix = ".new"
+
+// writes records the golden files written by the test binary, so that parallel
+// tests do not write the same file at the same time, and conflicting updates
+// to the same file are reported.
+var writes = writeTracker{
+	locks:   make(map[string]*sync.Mutex),
+	written: make(map[string]writeRecord),
+}
+
+type writeTracker struct {
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex
+	written map[string]writeRecord
+}
+
+type writeRecord struct {
+	hash     string
+	testName string
+}
+
+// lock acquires the lock for path, and returns a function that releases it.
+func (w *writeTracker) lock(path string) func() {
+	w.mu.Lock()
+	l, ok := w.locks[path]
+	if !ok {
+		l = new(sync.Mutex)
+		w.locks[path] = l
+	}
+	w.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// record stores the write to path, and returns an error if a different value
+// was already written to path by the test binary. The returned bool is false
+// if the same value was already written.
+func (w *writeTracker) record(path string, rec writeRecord) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	prev, ok := w.written[path]
+	switch {
+	case !ok:
+		w.written[path] = rec
+		return true, nil
+	case prev.hash == rec.hash:
+		return false, nil
+	}
+	return false, fmt.Errorf("conflicting updates to golden file %v: "+
+		"test %v wrote a value with hash %v, and test %v wrote a value with hash %v",
+		path, prev.testName, prev.hash, rec.testName, rec.hash)
+}
+
+// recordWrite records the write to path for target. If the write should be
+// skipped because the value was already written by another comparison, or
+// because it conflicts with a different value, the outcome is added to the
+// report.
+func recordWrite(path string, target updateTarget) (bool, error) {
+	ok, err := writes.record(path, writeRecord{hash: target.hash, testName: target.testName})
+	switch {
+	case err != nil:
+		report.add(target, outcomeConflict)
+	case !ok && update.review():
+		report.add(target, outcomePending)
+	case !ok:
+		report.add(target, outcomeUpdated)
+	}
+	return ok, err
+}
+
+// updateFile writes got to the golden file identified by target. The file is
+// written to a temporary file and renamed, so that the golden file is never
+// partially written.
+func updateFile(got string, target updateTarget) error {
+	filename := target.filename
+	if update.review() {
+		filename += PendingSuffix
+	}
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return fmt.Errorf("write wantfilename: %w", err)
+	}
+	defer writes.lock(path)()
+
+	ok, err := recordWrite(path, target)
+	if err != nil || !ok {
+		return err
+	}
+
+	result := outcomeUpdated
+	if _, err := os.Stat(target.filename); errors.Is(err, fs.ErrNotExist) {
+		result = outcomeCreated
+	}
+	if err := writeFileAtomic(path, []byte(got)); err != nil {
+		return fmt.Errorf("write wantfilename: %w", err)
+	}
+	if update.review() {
+		result = outcomePending
+		if !report.isEnabled() {
+			fmt.Printf("Wrote pending update to %v, run golden-review to accept it.\n", filename)
+		}
+	}
+	report.add(target, result)
+	return nil
+}
+
+// removePending removes the pending file for the golden file filename in
+// review mode, because the golden file already matches the value.
+func removePending(filename string) {
+	if update.review() {
+		_ = os.Remove(filename + PendingSuffix)
+	}
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,77 @@
+package golden
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUpdateFile_Parallel(t *testing.T) {
+	t.Setenv("GOLDEN_STRICT", "0")
+	patch(t, &update, "yes")
+	dir := filepath.Join(t.TempDir(), "new", "dir")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- MatchStringToFile("same value", filepath.Join(dir, "same.golden"))
+		}()
+		go func(i int) {
+			defer wg.Done()
+			errs <- MatchStringToFile("value", filepath.Join(dir, fmt.Sprintf("file%d.golden", i)))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), 11; got != want {
+		t.Fatalf("expected %d files, got %d: %v", want, got, entries)
+	}
+}
+
+func TestUpdateFile_Conflict(t *testing.T) {
+	t.Setenv("GOLDEN_STRICT", "0")
+	patch(t, &update, "yes")
+	patch(t, &report.entries, make(map[reportEntry]bool))
+	patch(t, &report.enabled, true)
+	filename := filepath.Join(t.TempDir(), "conflict.golden")
+
+	if err := MatchStringToFile("first", filename); err != nil {
+		t.Fatal(err)
+	}
+	err := MatchStringToFile("second", filename)
+	if err == nil {
+		t.Fatal("MatchStringToFile(): expected an error, got nil")
+	}
+	if got, want := err.Error(), "conflicting updates to golden file"; !strings.Contains(got, want) {
+		t.Fatalf("MatchStringToFile(): got %v, want %v", got, want)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "first"; got != want {
+		t.Fatalf("golden file: got %q, want %q", got, want)
+	}
+
+	entry := reportEntry{outcome: outcomeConflict, filename: filename, testName: "TestUpdateFile_Conflict"}
+	if !report.entries[entry] {
+		t.Fatalf("report: expected conflict entry, got %v", report.entries)
+	}
+}
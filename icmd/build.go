@@ -0,0 +1,219 @@
+package icmd
+
+import (
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/dnephin/vt/internal/cleanup"
+	"gotest.tools/v3/assert"
+)
+
+// BuildOp changes how BuildBinary builds a binary.
+type BuildOp func(*buildConfig)
+
+type buildConfig struct {
+	flags []string
+	env   []string
+}
+
+// WithBuildFlags adds flags to the go build command used by BuildBinary. For
+// example, WithBuildFlags("-tags=integration").
+func WithBuildFlags(flags ...string) BuildOp {
+	return func(c *buildConfig) {
+		c.flags = append(c.flags, flags...)
+	}
+}
+
+// WithBuildEnv adds variables to the environment of the go build command used
+// by BuildBinary. For example, WithBuildEnv("CGO_ENABLED=0").
+// Each argument is in the form of KEY=VALUE.
+func WithBuildEnv(env ...string) BuildOp {
+	return func(c *buildConfig) {
+		c.env = append(c.env, env...)
+	}
+}
+
+// builds caches the binaries built by BuildBinary.
+var builds = struct {
+	sync.Mutex
+	dir     string
+	entries map[string]*buildEntry
+	// cover is the set of binaries that were built with -cover.
+	cover map[string]bool
+	// users is the number of running tests that called BuildBinary.
+	users int
+}{entries: make(map[string]*buildEntry), cover: make(map[string]bool)}
+
+type buildEntry struct {
+	once   sync.Once
+	path   string
+	result *Result
+	err    error
+}
+
+// BuildBinary builds the main package pkg using go build, and returns the path
+// to the binary. pkg is any package argument accepted by go build, like
+// "./cmd/foo". The test fails if the package fails to build.
+//
+// Each package is only built once for each combination of BuildOp, and the
+// binary is reused by every test that calls BuildBinary while the binary
+// exists. If the test binary was built with -race or -cover the same flags are
+// used to build the binary. When -cover is used, commands that run the binary
+// write coverage data to a new directory in GOCOVERDIR, unless GOCOVERDIR is
+// already set in the environment of the command. See MergeCoverage. The
+// binary is built with the default -covermode, use WithBuildFlags to set a
+// different mode.
+//
+// The binaries are written to a temporary directory, which is removed when the
+// last running test that called BuildBinary ends. A test that runs after the
+// directory is removed builds the binary again. To build a binary once for a
+// group of tests, call BuildBinary from a parent test and run the tests as
+// subtests. CleanupBinaries removes the directory immediately.
+func BuildBinary(t assert.TestingT, pkg string, ops ...BuildOp) string {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	conf := buildConfig{flags: defaultBuildFlags()}
+	for _, op := range ops {
+		op(&conf)
+	}
+
+	key := strings.Join([]string{pkg, strings.Join(conf.flags, " "), strings.Join(conf.env, " ")}, "\x00")
+	builds.Lock()
+	entry, ok := builds.entries[key]
+	if !ok {
+		entry = &buildEntry{}
+		builds.entries[key] = entry
+	}
+	builds.users++
+	builds.Unlock()
+	cleanup.Cleanup(t, releaseBinaries)
+
+	entry.once.Do(func() {
+		entry.path, entry.result, entry.err = build(pkg, conf)
+	})
+	assert.NilError(t, entry.err)
+	entry.result.Assert(t, Success)
+	return entry.path
+}
+
+func build(pkg string, conf buildConfig) (string, *Result, error) {
+	dir, err := buildDir()
+	if err != nil {
+		return "", nil, err
+	}
+	name := path.Base(filepath.ToSlash(pkg))
+	if name == "." || name == "/" || name == "..." {
+		name = "main"
+	}
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	binary := filepath.Join(dir, name)
+
+	args := append([]string{"build", "-o", binary}, conf.flags...)
+	cmd := Command("go", append(args, pkg)...)
+	var ops []CmdOp
+	if len(conf.env) > 0 {
+		ops = append(ops, WithMergedEnv(conf.env...))
+	}
+	result := RunCmd(cmd, ops...)
+	if isCoverBuild(conf.flags) {
+		builds.Lock()
+		builds.cover[binary] = true
+		builds.Unlock()
+	}
+	return binary, result, nil
+}
+
+func isCoverBuild(flags []string) bool {
+	for _, flag := range flags {
+		if flag == "-cover" || strings.HasPrefix(flag, "-coverpkg") {
+			return true
+		}
+	}
+	return false
+}
+
+func isCoverBinary(binary string) bool {
+	builds.Lock()
+	defer builds.Unlock()
+	return builds.cover[binary]
+}
+
+// buildDir creates a new directory in the temporary directory used by
+// BuildBinary.
+func buildDir() (string, error) {
+	builds.Lock()
+	defer builds.Unlock()
+	if builds.dir == "" {
+		dir, err := os.MkdirTemp("", "icmd-build-")
+		if err != nil {
+			return "", err
+		}
+		builds.dir = dir
+	}
+	return os.MkdirTemp(builds.dir, "bin-")
+}
+
+// defaultBuildFlags returns the flags used to build the test binary that
+// should also be used to build binaries with BuildBinary.
+func defaultBuildFlags() []string {
+	var flags []string
+	if raceEnabled {
+		flags = append(flags, "-race")
+	}
+	if isCoverEnabled() {
+		flags = append(flags, "-cover")
+	}
+	return flags
+}
+
+// isCoverEnabled returns true if the test binary was built with -cover. The
+// build settings and flags are used, so that the package does not import
+// testing in non-test code.
+func isCoverEnabled() bool {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "-cover" {
+				return setting.Value == "true"
+			}
+		}
+	}
+	f := flag.Lookup("test.gocoverdir")
+	return f != nil && f.Value.String() != ""
+}
+
+// releaseBinaries removes the binaries built by BuildBinary when the last
+// running test that called BuildBinary ends.
+func releaseBinaries() {
+	builds.Lock()
+	defer builds.Unlock()
+	if builds.users--; builds.users > 0 {
+		return
+	}
+	removeBinaries()
+}
+
+// CleanupBinaries removes all the binaries built by BuildBinary.
+func CleanupBinaries() {
+	builds.Lock()
+	defer builds.Unlock()
+	removeBinaries()
+}
+
+// removeBinaries removes the build directory. builds must be locked.
+func removeBinaries() {
+	if builds.dir != "" {
+		_ = os.RemoveAll(builds.dir)
+	}
+	builds.dir = ""
+	builds.entries = make(map[string]*buildEntry)
+	builds.cover = make(map[string]bool)
+}
@@ -0,0 +1,83 @@
+package icmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestBuildBinary(t *testing.T) {
+	binary := BuildBinary(t, stubpath)
+	_, err := os.Stat(binary)
+	assert.NilError(t, err)
+	assert.Equal(t, BuildBinary(t, stubpath), binary, "expected the cached binary")
+
+	RunCommand(binary, "-warn").Assert(t, Expected{Out: "this is stdout", Err: "this is stderr"})
+
+	other := BuildBinary(t, stubpath, WithBuildFlags("-ldflags=-s"))
+	assert.Assert(t, other != binary)
+}
+
+func TestBuildBinary_RemovedAfterLastTest(t *testing.T) {
+	var binary string
+	t.Run("build", func(t *testing.T) {
+		binary = BuildBinary(t, stubpath)
+		t.Run("reuse", func(t *testing.T) {
+			assert.Equal(t, BuildBinary(t, stubpath), binary)
+		})
+		_, err := os.Stat(binary)
+		assert.NilError(t, err, "expected binary to exist until the test ends")
+	})
+	_, err := os.Stat(binary)
+	assert.Assert(t, os.IsNotExist(err), "expected binary to be removed, got %v", err)
+}
+
+func TestBuildBinary_Failed(t *testing.T) {
+	ft := &fakeAssertT{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		BuildBinary(ft, "./internal/doesnotexist")
+	}()
+	<-done
+	for _, f := range ft.cleanups {
+		f()
+	}
+	assert.Assert(t, ft.failed)
+	assert.Assert(t, cmp.Contains(strings.Join(ft.logs, "\n"), "go build -o"))
+}
+
+func TestDefaultBuildFlags(t *testing.T) {
+	flags := defaultBuildFlags()
+	assert.Equal(t, slices.Contains(flags, "-race"), raceEnabled)
+	assert.Equal(t, slices.Contains(flags, "-cover"), testing.CoverMode() != "")
+}
+
+type fakeAssertT struct {
+	failed   bool
+	logs     []string
+	cleanups []func()
+}
+
+func (t *fakeAssertT) Fail() {
+	t.failed = true
+}
+
+func (t *fakeAssertT) FailNow() {
+	t.failed = true
+	runtime.Goexit()
+}
+
+func (t *fakeAssertT) Log(args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *fakeAssertT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
@@ -0,0 +1,50 @@
+package icmd
+
+import (
+	"github.com/dnephin/vt/internal/cleanup"
+)
+
+// TestingT is the subset of testing.T used by StartCmdWithCleanup.
+type TestingT interface {
+	Log(args ...interface{})
+	Failed() bool
+}
+
+// StartCmdWithCleanup starts a command, like StartCmd, and registers a cleanup
+// function with t. If WaitOnCmd was not called by the test, the cleanup
+// function stops the command if it is still running, using Cmd.StopSequence,
+// and waits for it to exit. If the test failed, the output of the command is
+// written to the test log.
+//
+// The cleanup is skipped if the TEST_NOCLEANUP env var is set to true.
+func StartCmdWithCleanup(t TestingT, cmd Cmd, cmdOperators ...CmdOp) *Result {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	result := StartCmd(cmd, cmdOperators...)
+	if result.Error != nil {
+		return result
+	}
+	cleanup.Cleanup(t, func() {
+		result.cleanup(t)
+	})
+	return result
+}
+
+func (r *Result) cleanup(t TestingT) {
+	if r.waitCalled.Load() {
+		return
+	}
+	msg := "the command exited before the test ended:"
+	done := r.wait()
+	select {
+	case <-done:
+	default:
+		msg = "the command was stopped when the test ended:"
+		r.stopAndWait(done)
+	}
+	r.setExitError(r.waitErr)
+	if t.Failed() {
+		t.Log(msg + r.String())
+	}
+}
@@ -0,0 +1,74 @@
+package icmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type fakeT struct {
+	mu       sync.Mutex
+	failed   bool
+	logs     []string
+	cleanups []func()
+}
+
+func (t *fakeT) Log(args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logs = append(t.logs, fmt.Sprint(args...))
+}
+
+func (t *fakeT) Failed() bool {
+	return t.failed
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeT) runCleanups() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestStartCmdWithCleanup(t *testing.T) {
+	buildStub(t)
+
+	t.Run("stops the command", func(t *testing.T) {
+		ft := &fakeT{failed: true}
+		result := StartCmdWithCleanup(ft, Command(binname, "-sleep=5s"))
+		assert.NilError(t, result.Error)
+
+		ft.runCleanups()
+		assert.DeepEqual(t, result.Signals, []os.Signal{os.Kill})
+		assert.Assert(t, cmp.Len(ft.logs, 1))
+		assert.Assert(t, cmp.Contains(ft.logs[0], "the command was stopped when the test ended:"))
+		assert.Assert(t, cmp.Contains(ft.logs[0], "Command:  "+binname+" -sleep=5s"))
+	})
+
+	t.Run("test passed", func(t *testing.T) {
+		ft := &fakeT{}
+		result := StartCmdWithCleanup(ft, Command(binname, "-sleep=5s"))
+		assert.NilError(t, result.Error)
+
+		ft.runCleanups()
+		assert.DeepEqual(t, result.Signals, []os.Signal{os.Kill})
+		assert.Assert(t, cmp.Len(ft.logs, 0))
+	})
+
+	t.Run("after WaitOnCmd", func(t *testing.T) {
+		ft := &fakeT{failed: true}
+		result := StartCmdWithCleanup(ft, Command(binname))
+		WaitOnCmd(0, result).Assert(t, Expected{Out: "this is stdout"})
+
+		ft.runCleanups()
+		assert.Assert(t, cmp.Len(result.Signals, 0))
+		assert.Assert(t, cmp.Len(ft.logs, 0))
+	})
+}
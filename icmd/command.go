@@ -4,12 +4,15 @@ package icmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gotest.tools/v3/assert"
@@ -34,6 +37,12 @@ func (buf *lockedBuffer) Write(b []byte) (int, error) {
 	return buf.buf.Write(b)
 }
 
+func (buf *lockedBuffer) Len() int {
+	buf.m.RLock()
+	defer buf.m.RUnlock()
+	return buf.buf.Len()
+}
+
 func (buf *lockedBuffer) String() string {
 	buf.m.RLock()
 	defer buf.m.RUnlock()
@@ -46,9 +55,56 @@ type Result struct {
 	ExitCode int
 	Error    error
 	// Timeout is true if the command was killed because it ran for too long
-	Timeout   bool
-	outBuffer *lockedBuffer
-	errBuffer *lockedBuffer
+	Timeout bool
+	// Cause is the reason the command was stopped, from context.Cause, when the
+	// command was stopped because the context passed to RunCmdContext or
+	// StartCmdContext was done.
+	Cause error
+	// Signals are the signals that were sent to stop the command. See
+	// WithStopSequence.
+	Signals []os.Signal
+	// Duration is the wall-clock time from when the command started until it
+	// exited.
+	Duration time.Duration
+	// UserTime is the user CPU time of the command.
+	UserTime time.Duration
+	// SystemTime is the system CPU time of the command.
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size of the command in bytes. MaxRSS
+	// is only available on Linux.
+	MaxRSS int64
+
+	started      time.Time
+	ctx          context.Context
+	outBuffer    *lockedBuffer
+	errBuffer    *lockedBuffer
+	chunks       *chunkBuffer
+	interleaved  bool
+	stopSequence []StopSignal
+	onExit       []func()
+	onStart      []func(err error)
+	stdin        io.WriteCloser
+	stripANSI    bool
+	// inProcess receives the exit error of a command run in-process. See
+	// RegisterCommand.
+	inProcess chan error
+	// abandon is closed to stop waiting for a command run in-process, because
+	// the function can not be stopped.
+	abandon chan struct{}
+	// output stops the writes by a command run in-process after it is
+	// abandoned.
+	output *outputGate
+	// consumed is the length of the output matched by WaitForOutput.
+	consumed    int
+	stopOnce    sync.Once
+	signalsLock sync.Mutex
+	// exited is closed when the process exits, and done is closed after the
+	// result of the process is recorded in waitErr.
+	exited     chan struct{}
+	done       chan struct{}
+	waitOnce   sync.Once
+	waitErr    error
+	waitCalled atomic.Bool
 }
 
 // Assert compares the Result against the Expected struct, and fails the test if
@@ -78,9 +134,9 @@ func (r *Result) Compare(exp Expected) error {
 }
 
 func (r *Result) match(exp Expected) error {
-	errors := []string{}
+	failures := []string{}
 	add := func(format string, args ...interface{}) {
-		errors = append(errors, fmt.Sprintf(format, args...))
+		failures = append(failures, fmt.Sprintf(format, args...))
 	}
 
 	if exp.ExitCode != r.ExitCode {
@@ -93,12 +149,31 @@ func (r *Result) match(exp Expected) error {
 			add("Expected command to finish, but it hit the timeout")
 		}
 	}
+	if exp.MaxDuration != 0 && r.Duration > exp.MaxDuration {
+		add("Duration was %v expected at most %v", r.Duration, exp.MaxDuration)
+	}
+	if cpu := r.UserTime + r.SystemTime; exp.MaxCPUTime != 0 && cpu > exp.MaxCPUTime {
+		add("CPU time was %v expected at most %v", cpu, exp.MaxCPUTime)
+	}
+	if exp.MaxRSS != 0 && r.MaxRSS > exp.MaxRSS {
+		add("MaxRSS was %d bytes expected at most %d bytes", r.MaxRSS, exp.MaxRSS)
+	}
 	if !matchOutput(exp.Out, r.Stdout()) {
 		add("Expected stdout to contain %q", exp.Out)
 	}
 	if !matchOutput(exp.Err, r.Stderr()) {
 		add("Expected stderr to contain %q", exp.Err)
 	}
+	if exp.OutMatcher != nil {
+		if err := exp.OutMatcher(r.Stdout()); err != nil {
+			add("Expected stdout to match, but it %v", err)
+		}
+	}
+	if exp.ErrMatcher != nil {
+		if err := exp.ErrMatcher(r.Stderr()); err != nil {
+			add("Expected stderr to match, but it %v", err)
+		}
+	}
 	switch {
 	// If a non-zero exit code is expected there is going to be an error.
 	// Don't require an error message as well as an exit code because the
@@ -112,10 +187,10 @@ func (r *Result) match(exp Expected) error {
 		add("Expected error to contain %q", exp.Error)
 	}
 
-	if len(errors) == 0 {
+	if len(failures) == 0 {
 		return nil
 	}
-	return fmt.Errorf("%s\nFailures:\n%s", r, strings.Join(errors, "\n"))
+	return fmt.Errorf("%s\nFailures:\n%s", r, strings.Join(failures, "\n"))
 }
 
 func matchOutput(expected string, actual string) bool {
@@ -136,19 +211,30 @@ func (r *Result) String() string {
 	if r.Error != nil {
 		errString = "\nError:    " + r.Error.Error()
 	}
+	if signals := r.sentSignals(); len(signals) > 0 {
+		errString += fmt.Sprintf("\nSignals:  %v", signals)
+	}
+
+	output := fmt.Sprintf("Stdout:   %v\nStderr:   %v\n", r.Stdout(), r.Stderr())
+	if r.interleaved {
+		output += "Output:\n" + formatInterleaved(r.Interleaved())
+	}
+
+	var env string
+	if diff := envDiff(r.Cmd.Env); len(diff) > 0 {
+		env = "\nEnv:      " + strings.Join(diff, "\n          ")
+	}
 
 	return fmt.Sprintf(`
-Command:  %s
+Command:  %s%s
 ExitCode: %d%s%s
-Stdout:   %v
-Stderr:   %v
-`,
+%s`,
 		strings.Join(r.Cmd.Args, " "),
+		env,
 		r.ExitCode,
 		timeout,
 		errString,
-		r.Stdout(),
-		r.Stderr())
+		output)
 }
 
 // Expected is the expected output from a Command. This struct is compared to a
@@ -159,6 +245,18 @@ type Expected struct {
 	Error    string
 	Out      string
 	Err      string
+	// OutMatcher is used to compare stdout, in addition to Out. See Exact,
+	// Regexp, Lines, NotContains, and icmdgolden.Matcher.
+	OutMatcher Matcher
+	// ErrMatcher is used to compare stderr, in addition to Err.
+	ErrMatcher Matcher
+	// MaxDuration is the maximum wall-clock time of the command.
+	MaxDuration time.Duration
+	// MaxCPUTime is the maximum user and system CPU time of the command.
+	MaxCPUTime time.Duration
+	// MaxRSS is the maximum resident set size of the command in bytes. It is
+	// ignored on platforms where Result.MaxRSS is not available.
+	MaxRSS int64
 }
 
 // Success is the default expected result. A Success result is one with a 0
@@ -167,6 +265,9 @@ var Success = Expected{}
 
 // Stdout returns the stdout of the process as a string
 func (r *Result) Stdout() string {
+	if r.stripANSI {
+		return StripANSI(r.outBuffer.String())
+	}
 	return r.outBuffer.String()
 }
 
@@ -177,17 +278,36 @@ func (r *Result) Stderr() string {
 
 // Combined returns the stdout and stderr combined into a single string
 func (r *Result) Combined() string {
-	return r.outBuffer.String() + r.errBuffer.String()
+	return r.Stdout() + r.errBuffer.String()
 }
 
 func (r *Result) setExitError(err error) {
 	if err == nil {
 		return
 	}
+	if r.ctx != nil && r.ctx.Err() != nil {
+		r.setContextError()
+		return
+	}
 	r.Error = err
 	r.ExitCode = processExitCode(err)
 }
 
+// setContextError sets the fields of the result when the command was stopped
+// because the context was done. A command that exceeded the deadline of the
+// context is reported the same way as a command that exceeded Cmd.Timeout.
+func (r *Result) setContextError() {
+	r.Cause = context.Cause(r.ctx)
+	if errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+		r.Timeout = true
+		return
+	}
+	r.Error = r.Cause
+	if state := r.Cmd.ProcessState; state != nil {
+		r.ExitCode = state.ExitCode()
+	}
+}
+
 // Cmd contains the arguments and options for a process to run as part of a test
 // suite.
 type Cmd struct {
@@ -199,6 +319,25 @@ type Cmd struct {
 	Dir        string
 	Env        []string
 	ExtraFiles []*os.File
+	// StopSequence is the sequence of signals used to stop the command when it
+	// times out. The command is killed if it has not exited after the last step.
+	StopSequence []StopSignal
+
+	// onExit functions are called after the command exits, and all of the
+	// output has been written.
+	onExit []func()
+	// stdinPipe is true when the command should read stdin from a pipe. See
+	// WithStdinPipe.
+	stdinPipe bool
+	// pty is the configuration of the pseudo-terminal. See WithPTY.
+	pty *PTYConfig
+	// logStdout and logStderr receive the output of the command, in addition
+	// to Stdout and Stderr. See WithLogOutput.
+	logStdout []io.Writer
+	logStderr []io.Writer
+	// interleaved is true when Result.String should include the interleaved
+	// output. See WithInterleavedOutput.
+	interleaved bool
 }
 
 // Command create a simple Cmd with the specified command and arguments
@@ -208,8 +347,19 @@ func Command(command string, args ...string) Cmd {
 
 // RunCmd runs a command and returns a Result
 func RunCmd(cmd Cmd, cmdOperators ...CmdOp) *Result {
-	result := StartCmd(cmd, cmdOperators...)
-	if result.Error != nil {
+	return RunCmdContext(context.Background(), cmd, cmdOperators...)
+}
+
+// RunCmdContext runs a command and returns a Result. The command is killed if
+// ctx is done before the command exits. A context with the deadline from
+// t.Deadline can be used to stop the command before the test binary times out.
+//
+// If the deadline of ctx is exceeded Result.Timeout is true. If ctx is cancelled
+// Result.Error is set to the cause. In both cases Result.Cause is set to the
+// value returned by context.Cause.
+func RunCmdContext(ctx context.Context, cmd Cmd, cmdOperators ...CmdOp) *Result {
+	result := StartCmdContext(ctx, cmd, cmdOperators...)
+	if result.Error != nil || result.Timeout {
 		return result
 	}
 	return WaitOnCmd(cmd.Timeout, result)
@@ -222,74 +372,180 @@ func RunCommand(command string, args ...string) *Result {
 
 // StartCmd starts a command, but doesn't wait for it to finish
 func StartCmd(cmd Cmd, cmdOperators ...CmdOp) *Result {
+	return StartCmdContext(context.Background(), cmd, cmdOperators...)
+}
+
+// StartCmdContext starts a command, but doesn't wait for it to finish. The
+// command is killed if ctx is done before the command exits. See RunCmdContext.
+func StartCmdContext(ctx context.Context, cmd Cmd, cmdOperators ...CmdOp) *Result {
 	for _, op := range cmdOperators {
 		op(&cmd)
 	}
-	result := buildCmd(cmd)
+	if err := withBuildCoverage(&cmd); err != nil {
+		result := buildCmd(ctx, cmd)
+		result.setExitError(err)
+		return result
+	}
+	fn := lookupCommand(cmd.Command)
+	if fn != nil {
+		if err := checkInProcess(cmd); err != nil {
+			cmd.pty = nil
+			result := buildCmd(ctx, cmd)
+			result.setExitError(err)
+			return result
+		}
+	}
+	result := buildCmd(ctx, cmd)
 	if result.Error != nil {
 		return result
 	}
-	result.setExitError(result.Cmd.Start())
+	if fn != nil {
+		result.startInProcess(fn, cmd.Command[1:], cmd.stdinPipe)
+		return result
+	}
+	err := result.Cmd.Start()
+	result.started = time.Now()
+	for _, f := range result.onStart {
+		f(err)
+	}
+	result.setExitError(err)
 	return result
 }
 
-// TODO: support exec.CommandContext
-func buildCmd(cmd Cmd) *Result {
+func buildCmd(ctx context.Context, cmd Cmd) *Result {
 	var execCmd *exec.Cmd
 	switch len(cmd.Command) {
 	case 1:
-		execCmd = exec.Command(cmd.Command[0])
+		execCmd = exec.CommandContext(ctx, cmd.Command[0])
 	default:
-		execCmd = exec.Command(cmd.Command[0], cmd.Command[1:]...)
+		execCmd = exec.CommandContext(ctx, cmd.Command[0], cmd.Command[1:]...)
 	}
 	outBuffer := new(lockedBuffer)
 	errBuffer := new(lockedBuffer)
+	chunks := new(chunkBuffer)
 
 	execCmd.Stdin = cmd.Stdin
 	execCmd.Dir = cmd.Dir
 	execCmd.Env = cmd.Env
-	if cmd.Stdout != nil {
-		execCmd.Stdout = io.MultiWriter(outBuffer, cmd.Stdout)
-	} else {
-		execCmd.Stdout = outBuffer
+	execCmd.Stdout = multiWriter(chunks.writer("stdout", outBuffer), cmd.Stdout, cmd.logStdout)
+	execCmd.Stderr = multiWriter(chunks.writer("stderr", errBuffer), cmd.Stderr, cmd.logStderr)
+	execCmd.ExtraFiles = cmd.ExtraFiles
+	if len(cmd.StopSequence) > 0 {
+		setProcessGroup(execCmd)
 	}
-	if cmd.Stderr != nil {
-		execCmd.Stderr = io.MultiWriter(errBuffer, cmd.Stderr)
-	} else {
-		execCmd.Stderr = errBuffer
+	// A child process started by the command may keep the output pipes open
+	// after the command exits. Stop waiting for the pipes after pipeWaitDelay,
+	// and give the stop sequence time to finish before the process is killed
+	// because the context is done.
+	execCmd.WaitDelay = stopDuration(cmd.StopSequence) + pipeWaitDelay
+
+	result := &Result{
+		Cmd:          execCmd,
+		ctx:          ctx,
+		outBuffer:    outBuffer,
+		errBuffer:    errBuffer,
+		chunks:       chunks,
+		interleaved:  cmd.interleaved,
+		stopSequence: cmd.StopSequence,
+		onExit:       cmd.onExit,
+		exited:       make(chan struct{}),
+		done:         make(chan struct{}),
 	}
-	execCmd.ExtraFiles = cmd.ExtraFiles
+	// The stop sequence may take some time, and needs to know when the
+	// process exits, so run it in a goroutine.
+	execCmd.Cancel = func() error {
+		go result.stop()
+		return nil
+	}
+	switch {
+	case cmd.pty != nil:
+		result.setExitError(result.attachPTY(*cmd.pty, cmd.Stdin))
+	case cmd.stdinPipe:
+		stdin, err := execCmd.StdinPipe()
+		result.stdin = stdin
+		result.setExitError(err)
+	}
+	return result
+}
 
-	return &Result{
-		Cmd:       execCmd,
-		outBuffer: outBuffer,
-		errBuffer: errBuffer,
+// multiWriter returns a writer that writes to buf, the writer from Cmd.Stdout or
+// Cmd.Stderr if it is not nil, and each of the log writers.
+func multiWriter(buf io.Writer, w io.Writer, logs []io.Writer) io.Writer {
+	writers := []io.Writer{buf}
+	if w != nil {
+		writers = append(writers, w)
 	}
+	writers = append(writers, logs...)
+	if len(writers) == 1 {
+		return buf
+	}
+	return io.MultiWriter(writers...)
 }
 
 // WaitOnCmd waits for a command to complete. If timeout is non-nil then
 // only wait until the timeout.
+//
+// When the timeout is reached the command is stopped using Cmd.StopSequence,
+// and WaitOnCmd waits for the command to exit. If a child process started by
+// the command keeps stdout or stderr open after the command exits, any output
+// written after a short delay is not included in the result.
 func WaitOnCmd(timeout time.Duration, result *Result) *Result {
-	if timeout == time.Duration(0) {
-		result.setExitError(result.Cmd.Wait())
-		return result
-	}
+	result.waitCalled.Store(true)
+	done := result.wait()
 
-	done := make(chan error, 1)
-	// Wait for command to exit in a goroutine
-	go func() {
-		done <- result.Cmd.Wait()
-	}()
+	var timer <-chan time.Time
+	if timeout != time.Duration(0) {
+		timer = time.After(timeout)
+	}
 
 	select {
-	case <-time.After(timeout):
-		killErr := result.Cmd.Process.Kill()
-		if killErr != nil {
-			fmt.Printf("failed to kill (pid=%d): %v\n", result.Cmd.Process.Pid, killErr)
-		}
+	case <-timer:
+		result.stopAndWait(done)
 		result.Timeout = true
-	case err := <-done:
-		result.setExitError(err)
+	case <-done:
+		result.setExitError(result.waitErr)
 	}
 	return result
 }
+
+// wait for the command to exit in a goroutine. The returned channel is closed
+// once the command has exited.
+func (r *Result) wait() <-chan struct{} {
+	r.waitOnce.Do(func() {
+		go func() {
+			if r.inProcess != nil {
+				r.waitErr = r.waitInProcess()
+			} else {
+				r.waitErr = r.Cmd.Wait()
+			}
+			if errors.Is(r.waitErr, exec.ErrWaitDelay) {
+				// the command exited successfully, but a child process kept
+				// the output pipes open.
+				r.waitErr = nil
+			}
+			r.recordUsage()
+			for _, f := range r.onExit {
+				f()
+			}
+			close(r.exited)
+			// wait for any stop sequence in progress to record the signals it sent
+			r.stopOnce.Do(func() {})
+			close(r.done)
+		}()
+	})
+	return r.done
+}
+
+// stopAndWait stops the command, and waits for it to exit. Commands run
+// in-process can not be stopped, so stopAndWait only stops waiting for them.
+func (r *Result) stopAndWait(done <-chan struct{}) {
+	if r.inProcess != nil {
+		r.stopOnce.Do(func() {
+			close(r.abandon)
+		})
+		<-done
+		return
+	}
+	r.stop()
+	<-done
+}
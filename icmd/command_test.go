@@ -2,11 +2,14 @@ package icmd
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"syscall"
 	"testing"
 	"time"
 
@@ -31,6 +34,11 @@ func pathext() string {
 func TestMain(m *testing.M) {
 	exitcode := m.Run()
 	bindir.Remove()
+	if err := MergeCoverage(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitcode = 1
+	}
+	CleanupBinaries()
 	os.Exit(exitcode)
 }
 
@@ -179,3 +187,89 @@ func TestResult_Match_Match(t *testing.T) {
 	err := result.match(exp)
 	assert.NilError(t, err)
 }
+
+func TestRunCmdContextDeadlineExceeded(t *testing.T) {
+	buildStub(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	result := RunCmdContext(ctx, Command(binname, "-sleep=2s"))
+	result.Assert(t, Expected{Timeout: true, Out: None, Err: None})
+	assert.ErrorIs(t, result.Cause, context.DeadlineExceeded)
+}
+
+func TestRunCmdContextCancelled(t *testing.T) {
+	buildStub(t)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cause := errors.New("the test is done")
+	time.AfterFunc(30*time.Millisecond, func() { cancel(cause) })
+	result := RunCmdContext(ctx, Command(binname, "-sleep=2s"))
+	result.Assert(t, Expected{ExitCode: -1, Error: "the test is done"})
+	assert.Equal(t, result.Cause, cause)
+}
+
+func TestRunCmdContextFinished(t *testing.T) {
+	buildStub(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	result := RunCmdContext(ctx, Command(binname, "-sleep=1ms"))
+	result.Assert(t, Expected{Out: "this is stdout"})
+	assert.NilError(t, result.Cause)
+}
+
+func TestRunCmdWithStopSequence(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows does not support SIGTERM")
+	}
+	buildStub(t)
+
+	t.Run("exits after first signal", func(t *testing.T) {
+		cmd := Command(binname, "-sleep=5s", "-on-term=exit")
+		cmd.Timeout = 200 * time.Millisecond
+		result := RunCmd(cmd, WithStopSequence(StopSignal{Signal: syscall.SIGTERM, Wait: 5 * time.Second}))
+		result.Assert(t, Expected{Timeout: true, Out: "received terminated"})
+		assert.DeepEqual(t, result.Signals, []os.Signal{syscall.SIGTERM})
+	})
+
+	t.Run("killed after last signal", func(t *testing.T) {
+		cmd := Command(binname, "-sleep=5s", "-on-term=ignore")
+		cmd.Timeout = 200 * time.Millisecond
+		result := RunCmd(cmd, WithStopSequence(StopSignal{Signal: syscall.SIGTERM, Wait: 50 * time.Millisecond}))
+		result.Assert(t, Expected{Timeout: true, Out: None})
+		assert.DeepEqual(t, result.Signals, []os.Signal{syscall.SIGTERM, os.Kill})
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		cmd := Command(binname, "-sleep=5s", "-on-term=exit")
+		result := RunCmdContext(ctx, cmd, WithStopSequence(StopSignal{Signal: syscall.SIGTERM, Wait: 5 * time.Second}))
+		result.Assert(t, Expected{ExitCode: 3, Error: "context canceled", Out: "received terminated"})
+		assert.DeepEqual(t, result.Signals, []os.Signal{syscall.SIGTERM})
+	})
+}
+
+func TestRunCmdWithChildHoldingOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires sh")
+	}
+
+	t.Run("exits", func(t *testing.T) {
+		start := time.Now()
+		result := RunCmd(Command("sh", "-c", "sleep 3 & echo done"))
+		result.Assert(t, Expected{Out: "done"})
+		assert.Assert(t, time.Since(start) < 2*time.Second)
+		assert.Assert(t, result.Cmd.SysProcAttr == nil, "expected no new process group")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		cmd := Command("sh", "-c", "sleep 3 & sleep 3")
+		cmd.Timeout = 100 * time.Millisecond
+		result := RunCmd(cmd)
+		result.Assert(t, Expected{Timeout: true})
+		assert.Assert(t, time.Since(start) < 2*time.Second)
+	})
+}
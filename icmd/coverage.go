@@ -0,0 +1,209 @@
+package icmd
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gotest.tools/v3/assert"
+)
+
+// coverage is the directory that contains the coverage data written by
+// commands that use WithCoverage.
+var coverage = struct {
+	sync.Mutex
+	dir string
+}{}
+
+type namedT interface {
+	Name() string
+}
+
+// WithCoverage sets the GOCOVERDIR environment variable of the command to a new
+// directory for the test, so that a binary built with -cover writes coverage
+// data to the directory. See BuildBinary to build a binary with -cover.
+// WithCoverage is only necessary for binaries that were not built by
+// BuildBinary, or to name the directory after the test.
+//
+// MergeCoverage adds the coverage data from all of the commands to the
+// coverage profile of the test binary, so that the code run by the commands
+// is included in the coverage of the package.
+func WithCoverage(t assert.TestingT) CmdOp {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	name := "command"
+	if nt, ok := t.(namedT); ok {
+		name = nt.Name()
+	}
+	dir, err := coverageDir(name)
+	assert.NilError(t, err)
+	return WithMergedEnv("GOCOVERDIR=" + dir)
+}
+
+// withBuildCoverage sets GOCOVERDIR to a new directory when the command runs a
+// binary built by BuildBinary with -cover, and GOCOVERDIR is not already set.
+// Without GOCOVERDIR the binary prints a warning to stderr.
+func withBuildCoverage(cmd *Cmd) error {
+	if len(cmd.Command) == 0 || !isCoverBinary(cmd.Command[0]) {
+		return nil
+	}
+	for _, kv := range currentEnv(cmd) {
+		if containsKey([]string{"GOCOVERDIR"}, envKey(kv)) {
+			return nil
+		}
+	}
+	dir, err := coverageDir(filepath.Base(cmd.Command[0]))
+	if err != nil {
+		return fmt.Errorf("failed to create GOCOVERDIR: %w", err)
+	}
+	WithMergedEnv("GOCOVERDIR=" + dir)(cmd)
+	return nil
+}
+
+func coverageDir(name string) (string, error) {
+	coverage.Lock()
+	defer coverage.Unlock()
+	if coverage.dir == "" {
+		dir, err := os.MkdirTemp("", "icmd-coverage-")
+		if err != nil {
+			return "", err
+		}
+		coverage.dir = dir
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	return os.MkdirTemp(coverage.dir, name+"-")
+}
+
+// MergeCoverage adds the coverage data written by commands that used
+// WithCoverage, or that ran a binary built by BuildBinary with -cover, to the
+// coverage profile of the test binary, which is set by the -coverprofile flag
+// of go test. The coverage data is converted to the profile format using
+// `go tool covdata textfmt`. MergeCoverage must be called from TestMain after
+// m.Run, because the test binary writes the coverage profile at the end of
+// m.Run:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		if err := icmd.MergeCoverage(); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			code = 1
+//		}
+//		os.Exit(code)
+//	}
+//
+// The coverage data is removed after it is merged. If the test binary was not
+// run with -coverprofile the coverage data is removed without being merged.
+func MergeCoverage() error {
+	coverage.Lock()
+	defer coverage.Unlock()
+	if coverage.dir == "" {
+		return nil
+	}
+	defer func() {
+		_ = os.RemoveAll(coverage.dir)
+		coverage.dir = ""
+	}()
+
+	profile := coverProfile()
+	if profile == "" {
+		return nil
+	}
+	return mergeCoverage(coverage.dir, profile)
+}
+
+// mergeCoverage appends the coverage data in the directories in root to the
+// coverage profile.
+func mergeCoverage(root string, profile string) error {
+	dirs, err := coverageDataDirs(root)
+	if err != nil || len(dirs) == 0 {
+		return err
+	}
+
+	textfmt := filepath.Join(root, "coverage.txt")
+	result := RunCommand("go", "tool", "covdata", "textfmt",
+		"-i="+strings.Join(dirs, ","), "-o="+textfmt)
+	if err := result.Compare(Success); err != nil {
+		return fmt.Errorf("failed to convert coverage data: %w", err)
+	}
+	data, err := os.ReadFile(textfmt)
+	if err != nil {
+		return fmt.Errorf("failed to read coverage data: %w", err)
+	}
+	return appendCoverProfile(profile, data)
+}
+
+// coverProfile returns the path to the coverage profile written by the test
+// binary. The path is relative to the -test.outputdir.
+func coverProfile() string {
+	f := flag.Lookup("test.coverprofile")
+	if f == nil || f.Value.String() == "" {
+		return ""
+	}
+	profile := f.Value.String()
+	if out := flag.Lookup("test.outputdir"); out != nil && out.Value.String() != "" && !filepath.IsAbs(profile) {
+		profile = filepath.Join(out.Value.String(), profile)
+	}
+	return profile
+}
+
+// coverageDataDirs returns the directories in root that contain coverage data.
+func coverageDataDirs(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// appendCoverProfile appends the blocks from data, in the coverage profile
+// format, to the profile file. The mode line of data is not appended.
+func appendCoverProfile(profile string, data []byte) error {
+	f, err := os.OpenFile(profile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open coverage profile: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	w := bufio.NewWriter(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") || line == "" {
+			continue
+		}
+		_, _ = w.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write coverage profile: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,73 @@
+package icmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestWithCoverage(t *testing.T) {
+	binary := BuildBinary(t, stubpath, WithBuildFlags("-cover"))
+
+	cmd := applyOps(Command(binary), WithCoverage(t))
+	coverdir := lookupEnv(cmd.Env, "GOCOVERDIR")
+	assert.Assert(t, cmp.Contains(filepath.Base(coverdir), "TestWithCoverage-"))
+
+	RunCmd(cmd).Assert(t, Success)
+	files, err := os.ReadDir(coverdir)
+	assert.NilError(t, err)
+	assert.Assert(t, len(files) > 0, "expected coverage data in %v", coverdir)
+
+	profile := filepath.Join(t.TempDir(), "cover.out")
+	assert.NilError(t, os.WriteFile(profile, []byte("mode: set\n"), 0o644))
+	assert.NilError(t, mergeCoverage(filepath.Dir(coverdir), profile))
+
+	content, err := os.ReadFile(profile)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(string(content), "mode: set\n"))
+	assert.Equal(t, strings.Count(string(content), "mode:"), 1)
+	assert.Assert(t, cmp.Contains(string(content), "github.com/dnephin/vt/icmd/internal/stub/main.go:"))
+}
+
+func TestMergeCoverage_NoData(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "cover.out")
+	assert.NilError(t, os.WriteFile(profile, []byte("mode: set\n"), 0o644))
+	assert.NilError(t, mergeCoverage(t.TempDir(), profile))
+
+	content, err := os.ReadFile(profile)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), "mode: set\n")
+}
+
+func TestBuildBinary_Cover(t *testing.T) {
+	binary := BuildBinary(t, stubpath, WithBuildFlags("-cover"))
+
+	coverdir := t.TempDir()
+	result := RunCmd(Command(binary), WithMergedEnv("GOCOVERDIR="+coverdir))
+	result.Assert(t, Expected{Err: None})
+	assert.Equal(t, lookupEnv(result.Cmd.Env, "GOCOVERDIR"), coverdir)
+
+	// go test -cover sets GOCOVERDIR for the test binary
+	t.Setenv("GOCOVERDIR", "")
+	os.Unsetenv("GOCOVERDIR")
+	result = RunCommand(binary)
+	result.Assert(t, Expected{Err: None})
+	coverdir = lookupEnv(result.Cmd.Env, "GOCOVERDIR")
+	assert.Assert(t, cmp.Contains(filepath.Base(coverdir), filepath.Base(binary)+"-"))
+	files, err := os.ReadDir(coverdir)
+	assert.NilError(t, err)
+	assert.Assert(t, len(files) > 0, "expected coverage data in %v", coverdir)
+}
+
+func lookupEnv(env []string, key string) string {
+	for _, kv := range env {
+		if envKey(kv) == key {
+			return strings.TrimPrefix(kv, key+"=")
+		}
+	}
+	return ""
+}
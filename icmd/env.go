@@ -0,0 +1,145 @@
+package icmd
+
+import (
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/dnephin/vt/fs"
+)
+
+// WithMergedEnv adds env to the environment of the command, replacing any
+// existing variables with the same name. If the environment of the command
+// has not been set by WithEnv, or another CmdOp, env is added to os.Environ().
+// Each argument is in the form of KEY=VALUE.
+func WithMergedEnv(env ...string) CmdOp {
+	return func(c *Cmd) {
+		c.Env = mergeEnv(currentEnv(c), env)
+	}
+}
+
+// WithoutEnv removes the variables named by keys from the environment of the
+// command. If the environment of the command has not been set by WithEnv, or
+// another CmdOp, the variables are removed from os.Environ().
+func WithoutEnv(keys ...string) CmdOp {
+	return func(c *Cmd) {
+		env := []string{}
+		for _, kv := range currentEnv(c) {
+			if !containsKey(keys, envKey(kv)) {
+				env = append(env, kv)
+			}
+		}
+		c.Env = env
+	}
+}
+
+// hermeticEnv is the list of variables from os.Environ() that are always
+// included by WithHermeticEnv. These variables are required to run most
+// commands.
+var hermeticEnv = []string{"PATH", "TMPDIR", "TMP", "TEMP", "SYSTEMROOT", "COMSPEC", "PATHEXT"}
+
+// WithHermeticEnv sets the environment of the command to a minimal set of
+// variables from os.Environ(), so that the environment of the developer or
+// CI system does not change the behaviour of the command. Only PATH, the
+// variables that set the temporary directory, and the variables required to
+// run commands on windows, are included, along with any variables named in
+// allow.
+//
+// WithHermeticEnv replaces the environment of the command, so any other CmdOp
+// that changes the environment, like WithMergedEnv or WithHomeDir, must be used
+// after WithHermeticEnv.
+func WithHermeticEnv(allow ...string) CmdOp {
+	return func(c *Cmd) {
+		keys := append(append([]string{}, hermeticEnv...), allow...)
+		env := []string{}
+		for _, kv := range os.Environ() {
+			if containsKey(keys, envKey(kv)) {
+				env = append(env, kv)
+			}
+		}
+		c.Env = env
+	}
+}
+
+// WithHomeDir sets HOME of the command to dir, and sets the XDG base directory
+// variables to the default locations in dir. On windows USERPROFILE is also
+// set to dir. The variables are merged with the existing environment of the
+// command, like WithMergedEnv.
+func WithHomeDir(dir *fs.Dir) CmdOp {
+	home := dir.Path()
+	env := []string{
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + dir.Join(".config"),
+		"XDG_CACHE_HOME=" + dir.Join(".cache"),
+		"XDG_DATA_HOME=" + dir.Join(".local", "share"),
+		"XDG_STATE_HOME=" + dir.Join(".local", "state"),
+	}
+	if runtime.GOOS == "windows" {
+		env = append(env, "USERPROFILE="+home)
+	}
+	return WithMergedEnv(env...)
+}
+
+func currentEnv(c *Cmd) []string {
+	if c.Env == nil {
+		return os.Environ()
+	}
+	return c.Env
+}
+
+// mergeEnv returns env with the variables from overrides added, replacing any
+// variables with the same name.
+func mergeEnv(env []string, overrides []string) []string {
+	var keys []string
+	for _, kv := range overrides {
+		keys = append(keys, envKey(kv))
+	}
+	merged := []string{}
+	for _, kv := range env {
+		if !containsKey(keys, envKey(kv)) {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// envDiff returns the variables in env that are not in os.Environ(), or that
+// have a different value, followed by "unset KEY" for each variable in
+// os.Environ() that is not in env. Result.String only prints the difference,
+// because the environment of the test may contain secrets. envDiff returns nil
+// if env is nil, because the command uses os.Environ().
+func envDiff(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	environ := os.Environ()
+	var diff, keys []string
+	for _, kv := range env {
+		keys = append(keys, envKey(kv))
+		if !slices.Contains(environ, kv) {
+			diff = append(diff, kv)
+		}
+	}
+	for _, kv := range environ {
+		if key := envKey(kv); !containsKey(keys, key) {
+			diff = append(diff, "unset "+key)
+		}
+	}
+	return diff
+}
+
+func envKey(kv string) string {
+	key, _, _ := strings.Cut(kv, "=")
+	return key
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		// variable names are case insensitive on windows
+		if k == key || (runtime.GOOS == "windows" && strings.EqualFold(k, key)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package icmd
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dnephin/vt/fs"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func applyOps(cmd Cmd, ops ...CmdOp) Cmd {
+	for _, op := range ops {
+		op(&cmd)
+	}
+	return cmd
+}
+
+func TestWithMergedEnv(t *testing.T) {
+	t.Setenv("ICMD_TEST_A", "original")
+	t.Setenv("ICMD_TEST_B", "original")
+
+	cmd := applyOps(Cmd{}, WithMergedEnv("ICMD_TEST_A=new", "ICMD_TEST_C=new"))
+	assert.Assert(t, cmp.Contains(cmd.Env, "ICMD_TEST_A=new"))
+	assert.Assert(t, cmp.Contains(cmd.Env, "ICMD_TEST_B=original"))
+	assert.Assert(t, cmp.Contains(cmd.Env, "ICMD_TEST_C=new"))
+	assert.Assert(t, !slices.Contains(cmd.Env, "ICMD_TEST_A=original"))
+
+	cmd = applyOps(Cmd{}, WithEnv("ONE=1", "TWO=2"), WithMergedEnv("TWO=two", "THREE=3"))
+	assert.DeepEqual(t, cmd.Env, []string{"ONE=1", "TWO=two", "THREE=3"})
+}
+
+func TestWithoutEnv(t *testing.T) {
+	t.Setenv("ICMD_TEST_A", "original")
+
+	cmd := applyOps(Cmd{}, WithoutEnv("ICMD_TEST_A"))
+	assert.Assert(t, !slices.Contains(cmd.Env, "ICMD_TEST_A=original"))
+	assert.Assert(t, len(cmd.Env) > 0)
+
+	cmd = applyOps(Cmd{}, WithEnv("ONE=1", "TWO=2"), WithoutEnv("ONE", "THREE"))
+	assert.DeepEqual(t, cmd.Env, []string{"TWO=2"})
+
+	cmd = applyOps(Cmd{}, WithEnv("ONE=1"), WithoutEnv("ONE"))
+	assert.DeepEqual(t, cmd.Env, []string{})
+}
+
+func TestWithHermeticEnv(t *testing.T) {
+	t.Setenv("PATH", "/bin")
+	t.Setenv("ICMD_TEST_A", "a")
+	t.Setenv("ICMD_TEST_B", "b")
+
+	cmd := applyOps(Cmd{}, WithHermeticEnv("ICMD_TEST_B"))
+	assert.Assert(t, cmp.Contains(cmd.Env, "PATH=/bin"))
+	assert.Assert(t, cmp.Contains(cmd.Env, "ICMD_TEST_B=b"))
+	assert.Assert(t, !slices.Contains(cmd.Env, "ICMD_TEST_A=a"))
+}
+
+func TestWithHomeDir(t *testing.T) {
+	home := fs.NewDir(t, "home")
+	cmd := applyOps(Cmd{}, WithEnv("ONE=1", "HOME=/root"), WithHomeDir(home))
+	assert.Assert(t, cmp.Contains(cmd.Env, "ONE=1"))
+	assert.Assert(t, cmp.Contains(cmd.Env, "HOME="+home.Path()))
+	assert.Assert(t, cmp.Contains(cmd.Env, "XDG_CONFIG_HOME="+home.Join(".config")))
+	assert.Assert(t, cmp.Contains(cmd.Env, "XDG_DATA_HOME="+home.Join(".local", "share")))
+	assert.Assert(t, !slices.Contains(cmd.Env, "HOME=/root"))
+}
+
+func TestResult_String_Env(t *testing.T) {
+	t.Setenv("ICMD_TEST_A", "original")
+	t.Setenv("ICMD_TEST_B", "secret")
+	t.Setenv("ICMD_TEST_C", "unchanged")
+	cmd := applyOps(Cmd{},
+		WithMergedEnv("ICMD_TEST_A=new", "ONE=1"),
+		WithoutEnv("ICMD_TEST_B"))
+	result := &Result{
+		Cmd:       exec.Command("binary", "arg1"),
+		outBuffer: newLockedBuffer("the output"),
+		errBuffer: newLockedBuffer(""),
+	}
+	result.Cmd.Env = cmd.Env
+	expected := `
+Command:  binary arg1
+Env:      ICMD_TEST_A=new
+          ONE=1
+          unset ICMD_TEST_B
+ExitCode: 0
+Stdout:   the output
+Stderr:   
+`
+	assert.Equal(t, result.String(), expected)
+
+	result.Cmd.Env = os.Environ()
+	assert.Assert(t, !strings.Contains(result.String(), "Env:"))
+}
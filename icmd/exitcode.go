@@ -2,6 +2,7 @@ package icmd
 
 import (
 	"errors"
+	"fmt"
 	"os/exec"
 )
 
@@ -10,6 +11,11 @@ func processExitCode(err error) int {
 		return 0
 	}
 
+	var codeErr exitCodeError
+	if errors.As(err, &codeErr) {
+		return int(codeErr)
+	}
+
 	var exitErr *exec.ExitError
 	if errors.As(err, &exitErr) {
 		if exitErr.ProcessState == nil {
@@ -21,3 +27,12 @@ func processExitCode(err error) int {
 	}
 	return 127
 }
+
+// exitCodeError is the error returned by a command run in-process when it
+// returns a non-zero exit code. The message matches the message of
+// exec.ExitError.
+type exitCodeError int
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
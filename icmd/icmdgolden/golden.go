@@ -0,0 +1,83 @@
+/*
+Package icmdgolden compares the output of commands run by package icmd to
+golden files. See package golden for details about the comparison, and how to
+update golden files with -update.
+
+The functions are in a separate package so that importing icmd does not add the
+-update flag, which is registered by package golden, to the test binary.
+*/
+package icmdgolden
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dnephin/vt/golden"
+	"github.com/dnephin/vt/icmd"
+)
+
+// Assert compares the combined stdout and stderr of the command to the golden
+// file filename, and fails the test if they are not equal. AssertStdout and
+// AssertStderr can be used to compare only stdout or stderr.
+//
+// If Cmd.Dir of the result is a temporary directory, the path to the directory
+// is replaced with golden.TempDirPlaceholder before the comparison.
+func Assert(t golden.TestingT, result *icmd.Result, filename string, opts ...golden.Option) {
+	t.Helper()
+	golden.Assert(t, result.Combined(), filename, options(result, opts)...)
+}
+
+// AssertStdout compares the stdout of the command to the golden file filename,
+// and fails the test if they are not equal. See Assert.
+func AssertStdout(t golden.TestingT, result *icmd.Result, filename string, opts ...golden.Option) {
+	t.Helper()
+	golden.Assert(t, result.Stdout(), filename, options(result, opts)...)
+}
+
+// AssertStderr compares the stderr of the command to the golden file filename,
+// and fails the test if they are not equal. See Assert.
+func AssertStderr(t golden.TestingT, result *icmd.Result, filename string, opts ...golden.Option) {
+	t.Helper()
+	golden.Assert(t, result.Stderr(), filename, options(result, opts)...)
+}
+
+// Matcher returns an icmd.Matcher that compares the output to the golden file
+// filename. It can be used as Expected.OutMatcher or Expected.ErrMatcher:
+//
+//	result.Assert(t, icmd.Expected{
+//		OutMatcher: icmdgolden.Matcher("testdata/stdout.golden"),
+//	})
+//
+// The Matcher does not have access to Cmd.Dir, so use golden.WithTempDir to
+// replace the path to a temporary directory.
+func Matcher(filename string, opts ...golden.Option) icmd.Matcher {
+	return func(actual string) error {
+		if err := golden.MatchStringToFile(actual, filename, opts...); err != nil {
+			return fmt.Errorf("does not match golden file %v:\n%w", filename, err)
+		}
+		return nil
+	}
+}
+
+func options(result *icmd.Result, opts []golden.Option) []golden.Option {
+	if result.Cmd == nil || !isTempDir(result.Cmd.Dir) {
+		return opts
+	}
+	return append([]golden.Option{golden.WithTempDir(result.Cmd.Dir)}, opts...)
+}
+
+// isTempDir returns true if dir is in the default directory for temporary
+// files.
+func isTempDir(dir string) bool {
+	if dir == "" {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(os.TempDir(), abs)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
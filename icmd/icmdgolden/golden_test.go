@@ -0,0 +1,69 @@
+package icmdgolden
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dnephin/vt/icmd"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func registerWarn(t *testing.T) {
+	icmd.RegisterCommand(t, "warn", func(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+		fmt.Fprintln(stdout, "this is stdout")
+		fmt.Fprintln(stderr, "this is stderr")
+		return 0
+	})
+}
+
+func TestAssert(t *testing.T) {
+	registerWarn(t)
+
+	result := icmd.RunCommand("warn")
+	result.Assert(t, icmd.Expected{
+		OutMatcher: Matcher("testdata/stdout.golden"),
+		ErrMatcher: Matcher("testdata/stderr.golden"),
+	})
+	Assert(t, result, "testdata/combined.golden")
+	AssertStdout(t, result, "testdata/stdout.golden")
+	AssertStderr(t, result, "testdata/stderr.golden")
+}
+
+func TestAssert_TempDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires echo")
+	}
+	// Cmd.Dir is not supported by commands registered by RegisterCommand
+	dir := t.TempDir()
+	cmd := icmd.Command("echo", "created", filepath.Join(dir, "file.txt"))
+	cmd.Dir = dir
+
+	result := icmd.RunCmd(cmd)
+	Assert(t, result, "testdata/tempdir.golden")
+}
+
+func TestMatcher_NotMatched(t *testing.T) {
+	registerWarn(t)
+	filename := filepath.Join(t.TempDir(), "stdout.golden")
+	assert.NilError(t, os.WriteFile(filename, []byte("the output\n"), 0o644))
+
+	result := icmd.RunCommand("warn")
+	err := result.Compare(icmd.Expected{OutMatcher: Matcher(filename)})
+	assert.Assert(t, cmp.Contains(err.Error(), `
+Failures:
+Expected stdout to match, but it does not match golden file `+filename+`:
+(-got +want):
+--- got
++++ want
+@@ -1,2 +1,2 @@
+-this is stdout
++the output
+ 
+`))
+	assert.Assert(t, cmp.Contains(err.Error(), "\n\tgo test github.com/dnephin/vt/icmd/icmdgolden -update="))
+}
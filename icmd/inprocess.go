@@ -0,0 +1,194 @@
+package icmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnephin/vt/internal/cleanup"
+)
+
+// CommandFunc is the main function of a command that is run in-process. args
+// are the arguments of the command, not including the name of the command.
+// The returned value is used as the exit code of the command.
+type CommandFunc func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// commands are the commands registered by RegisterCommand.
+var commands = struct {
+	sync.Mutex
+	funcs map[string]CommandFunc
+}{funcs: make(map[string]CommandFunc)}
+
+// RegisterCommand registers fn as the command name until the test ends. When
+// Cmd.Command[0] is name, RunCmd and StartCmd call fn in a new goroutine,
+// instead of starting a new process. The Result of the command contains the
+// exit code and output, so the same Expected can be used to test the command
+// in-process, or as a subprocess. A panic in fn is written to stderr, and the
+// command exits with code 2.
+//
+// Running a command in-process avoids building a binary and starting a
+// process, but fn shares the working directory, environment, and global state
+// of the test binary. The command fails to start if Cmd.Dir, Cmd.Env, or
+// Cmd.ExtraFiles are set, or if WithPTY is used. An in-process command can not
+// be stopped, so when the timeout is reached, or the context passed to
+// RunCmdContext is done, the Result is returned while fn continues to run in
+// the background. After that point writes by fn to stdout and stderr return an
+// error, and are not added to the Result.
+//
+// The registered commands are shared by all the tests in the test binary. A
+// subtest may register a command with the same name as its parent test, and
+// the command of the parent is restored when the subtest ends. Parallel tests
+// must use a different name for each test, because a registration by one test
+// replaces the command for all the tests that are running.
+func RegisterCommand(t TestingT, name string, fn CommandFunc) {
+	if ht, ok := t.(helperT); ok {
+		ht.Helper()
+	}
+	commands.Lock()
+	prev, ok := commands.funcs[name]
+	commands.funcs[name] = fn
+	commands.Unlock()
+
+	cleanup.Cleanup(t, func() {
+		commands.Lock()
+		defer commands.Unlock()
+		if ok {
+			commands.funcs[name] = prev
+			return
+		}
+		delete(commands.funcs, name)
+	})
+}
+
+func lookupCommand(command []string) CommandFunc {
+	if len(command) == 0 {
+		return nil
+	}
+	commands.Lock()
+	defer commands.Unlock()
+	return commands.funcs[command[0]]
+}
+
+// startInProcess runs fn in a new goroutine. The exit error of fn is sent to
+// r.inProcess.
+func (r *Result) startInProcess(fn CommandFunc, args []string, stdinPipe bool) {
+	stdin := r.Cmd.Stdin
+	if stdin == nil {
+		stdin = strings.NewReader("")
+	}
+	if closer, ok := stdin.(io.Closer); ok && stdinPipe {
+		// close the read side of the pipe created by WithStdinPipe
+		r.onExit = append(r.onExit, func() {
+			_ = closer.Close()
+		})
+	}
+
+	r.inProcess = make(chan error, 1)
+	r.abandon = make(chan struct{})
+	r.output = new(outputGate)
+	stdout, stderr := r.output.writer(r.Cmd.Stdout), r.output.writer(r.Cmd.Stderr)
+	r.started = time.Now()
+	for _, f := range r.onStart {
+		f(nil)
+	}
+	go func() {
+		r.inProcess <- runInProcess(fn, args, stdin, stdout, stderr)
+	}()
+}
+
+// checkInProcess returns an error if cmd uses an option that is not supported
+// by a command registered by RegisterCommand.
+func checkInProcess(cmd Cmd) error {
+	var unsupported []string
+	if cmd.Dir != "" {
+		unsupported = append(unsupported, "Cmd.Dir")
+	}
+	if len(cmd.Env) > 0 {
+		unsupported = append(unsupported, "Cmd.Env")
+	}
+	if len(cmd.ExtraFiles) > 0 {
+		unsupported = append(unsupported, "Cmd.ExtraFiles")
+	}
+	if cmd.pty != nil {
+		unsupported = append(unsupported, "WithPTY")
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v can not be used with a command registered by RegisterCommand",
+		strings.Join(unsupported, ", "))
+}
+
+// outputGate stops the writes to stdout and stderr by a command run in-process
+// once the command is abandoned, so that the command does not change the
+// Result after it is returned.
+type outputGate struct {
+	m      sync.RWMutex
+	closed bool
+}
+
+func (g *outputGate) writer(w io.Writer) io.Writer {
+	return gatedWriter{gate: g, w: w}
+}
+
+// close stops all writes. close waits for any write in progress to finish.
+func (g *outputGate) close() {
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.closed = true
+}
+
+type gatedWriter struct {
+	gate *outputGate
+	w    io.Writer
+}
+
+func (w gatedWriter) Write(b []byte) (int, error) {
+	w.gate.m.RLock()
+	defer w.gate.m.RUnlock()
+	if w.gate.closed {
+		return 0, errAbandoned
+	}
+	return w.w.Write(b)
+}
+
+// waitInProcess waits for the function started by startInProcess to return,
+// and returns the exit error. If the context is done, or the command is
+// abandoned by stopAndWait, waitInProcess returns without waiting for the
+// function.
+func (r *Result) waitInProcess() error {
+	var ctxDone <-chan struct{}
+	if r.ctx != nil {
+		ctxDone = r.ctx.Done()
+	}
+	select {
+	case err := <-r.inProcess:
+		return err
+	case <-ctxDone:
+		r.output.close()
+		return context.Cause(r.ctx)
+	case <-r.abandon:
+		r.output.close()
+		return errAbandoned
+	}
+}
+
+var errAbandoned = errors.New("stopped waiting for the command run in-process")
+
+func runInProcess(fn CommandFunc, args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			fmt.Fprintf(stderr, "panic: %v\n\n%s", v, debug.Stack())
+			err = exitCodeError(2)
+		}
+	}()
+	if code := fn(args, stdin, stdout, stderr); code != 0 {
+		return exitCodeError(code)
+	}
+	return nil
+}
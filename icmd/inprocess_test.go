@@ -0,0 +1,148 @@
+package icmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func greet(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "-prompt" {
+		fmt.Fprint(stdout, "Name: ")
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			fmt.Fprintf(stdout, "Hello %v\n", scanner.Text())
+		}
+		return 0
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "expected one argument")
+		return 3
+	}
+	if args[0] == "panic" {
+		panic("oops")
+	}
+	fmt.Fprintf(stdout, "Hello %v\n", args[0])
+	return 0
+}
+
+func TestRegisterCommand(t *testing.T) {
+	RegisterCommand(t, "greet", greet)
+
+	result := RunCommand("greet", "world")
+	result.Assert(t, Expected{Out: "Hello world\n", Err: None})
+	assert.Assert(t, result.Duration > 0)
+
+	result = RunCommand("greet")
+	result.Assert(t, Expected{
+		ExitCode: 3,
+		Error:    "exit status 3",
+		Out:      None,
+		Err:      "expected one argument\n",
+	})
+
+	result = RunCmd(Command("greet", "stdin"), WithStdin(strings.NewReader("ignored")))
+	result.Assert(t, Expected{Out: "Hello stdin\n"})
+}
+
+func TestRegisterCommand_Panic(t *testing.T) {
+	RegisterCommand(t, "greet", greet)
+
+	result := RunCommand("greet", "panic")
+	result.Assert(t, Expected{ExitCode: 2, Err: "panic: oops\n"})
+}
+
+func TestRegisterCommand_Interactive(t *testing.T) {
+	RegisterCommand(t, "greet", greet)
+
+	result := StartCmd(Command("greet", "-prompt"), WithStdinPipe())
+	assert.NilError(t, result.Error)
+	result.WaitForOutput(t, regexp.MustCompile(`Name: `), 5*time.Second)
+	assert.NilError(t, result.SendLine("in-process"))
+	result.WaitForOutput(t, regexp.MustCompile(`Hello in-process`), 5*time.Second)
+	assert.NilError(t, result.CloseStdin())
+	WaitOnCmd(5*time.Second, result).Assert(t, Success)
+}
+
+func TestRegisterCommand_Timeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	RegisterCommand(t, "block", func([]string, io.Reader, io.Writer, io.Writer) int {
+		<-block
+		return 0
+	})
+
+	cmd := Command("block")
+	cmd.Timeout = 10 * time.Millisecond
+	result := RunCmd(cmd)
+	result.Assert(t, Expected{Timeout: true})
+	assert.Assert(t, result.Duration > 0)
+}
+
+func TestRegisterCommand_WriteAfterTimeout(t *testing.T) {
+	block := make(chan struct{})
+	written := make(chan error)
+	RegisterCommand(t, "block", func(_ []string, _ io.Reader, stdout, _ io.Writer) int {
+		fmt.Fprint(stdout, "before")
+		<-block
+		_, err := fmt.Fprint(stdout, "after")
+		written <- err
+		return 0
+	})
+
+	cmd := Command("block")
+	cmd.Timeout = 10 * time.Millisecond
+	result := RunCmd(cmd)
+	result.Assert(t, Expected{Timeout: true, Out: "before"})
+
+	close(block)
+	assert.ErrorIs(t, <-written, errAbandoned)
+	assert.Equal(t, result.Stdout(), "before")
+}
+
+func TestRegisterCommand_Unsupported(t *testing.T) {
+	RegisterCommand(t, "greet", greet)
+
+	cmd := Command("greet", "x")
+	cmd.Dir = t.TempDir()
+	result := RunCmd(cmd, WithEnv("A=b"))
+	assert.ErrorContains(t, result.Error,
+		"Cmd.Dir, Cmd.Env can not be used with a command registered by RegisterCommand")
+	assert.Equal(t, result.Stdout(), "")
+}
+
+func TestRegisterCommand_ContextDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	RegisterCommand(t, "block", func([]string, io.Reader, io.Writer, io.Writer) int {
+		<-block
+		return 0
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := RunCmdContext(ctx, Command("block"))
+	assert.Assert(t, time.Since(start) < 2*time.Second)
+	result.Assert(t, Expected{Timeout: true})
+	assert.Assert(t, errors.Is(result.Cause, context.DeadlineExceeded), result.Cause)
+}
+
+func TestRegisterCommand_Unregistered(t *testing.T) {
+	t.Run("register", func(t *testing.T) {
+		RegisterCommand(t, "icmd-test-greet", greet)
+		RunCommand("icmd-test-greet", "x").Assert(t, Success)
+	})
+
+	result := RunCommand("icmd-test-greet", "x")
+	assert.Assert(t, cmp.ErrorContains(result.Error, "executable file not found"))
+}
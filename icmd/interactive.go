@@ -0,0 +1,107 @@
+package icmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/dnephin/vt/poll"
+)
+
+// WithStdinPipe connects stdin of the command to a pipe, so that input can be
+// sent to a started command using Result.SendLine. WithStdinPipe can not be
+// used with WithStdin.
+//
+// WithStdinPipe can be used with StartCmd and WaitForOutput to test commands
+// that prompt for input:
+//
+//	result := icmd.StartCmd(icmd.Command("app", "init"), icmd.WithStdinPipe())
+//	result.WaitForOutput(t, regexp.MustCompile(`Name: $`), time.Second)
+//	assert.NilError(t, result.SendLine("example"))
+//	assert.NilError(t, result.CloseStdin())
+//	icmd.WaitOnCmd(time.Second, result).Assert(t, icmd.Success)
+func WithStdinPipe() CmdOp {
+	return func(c *Cmd) {
+		c.stdinPipe = true
+	}
+}
+
+var errNoStdinPipe = errors.New("stdin is not a pipe, use WithStdinPipe to send input")
+
+// SendLine writes line, followed by a newline, to stdin of the command. The
+// command must be started using WithStdinPipe.
+func (r *Result) SendLine(line string) error {
+	if r.stdin == nil {
+		return errNoStdinPipe
+	}
+	_, err := io.WriteString(r.stdin, line+"\n")
+	return err
+}
+
+// CloseStdin closes stdin of the command. The command must be started using
+// WithStdinPipe.
+func (r *Result) CloseStdin() error {
+	if r.stdin == nil {
+		return errNoStdinPipe
+	}
+	return r.stdin.Close()
+}
+
+// WaitForOutput waits for the output of a started command to match re, and
+// returns the text that matched. The output from both stdout and stderr is
+// matched, in the order it was received. Each call only matches output
+// received after the text matched by the previous call to WaitForOutput.
+//
+// WaitForOutput uses poll.WaitOn, and fails the test if the output does not
+// match before the timeout, or if the command exits before the output matches.
+func (r *Result) WaitForOutput(t poll.TestingT, re *regexp.Regexp, timeout time.Duration) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ctx = poll.WaitWithDelay(ctx, 10*time.Millisecond)
+
+	done := r.wait()
+	// The check may still be running in a goroutine after WaitOn returns, so
+	// it only reads start, and the match is stored using the mutex.
+	start := r.consumed
+	var match struct {
+		sync.Mutex
+		found bool
+		text  string
+		end   int
+	}
+	poll.WaitOn(ctx, t, func(ctx context.Context, _ poll.LogT) error {
+		var exited bool
+		select {
+		case <-done:
+			exited = true
+		default:
+		}
+
+		// The output of both streams is stored in the lockedBuffer of each
+		// stream. chunks combines them in the order they were read.
+		output := r.chunks.String()[start:]
+		if loc := re.FindStringIndex(output); loc != nil {
+			match.Lock()
+			defer match.Unlock()
+			match.found, match.text, match.end = true, output[loc[0]:loc[1]], start+loc[1]
+			return nil
+		}
+		err := fmt.Errorf("output did not match %q: %q", re, output)
+		if exited {
+			return fmt.Errorf("the command exited and the %w", err)
+		}
+		return poll.Continue(err)
+	})
+
+	match.Lock()
+	defer match.Unlock()
+	if match.found {
+		r.consumed = match.end
+	}
+	return match.text
+}
@@ -0,0 +1,71 @@
+package icmd
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestInteractive(t *testing.T) {
+	buildStub(t)
+
+	result := StartCmd(Command(binname, "-prompt"), WithStdinPipe())
+	assert.NilError(t, result.Error)
+
+	assert.Equal(t, result.WaitForOutput(t, regexp.MustCompile(`Name: $`), 5*time.Second), "Name: ")
+	assert.NilError(t, result.SendLine("first"))
+	assert.Equal(t, result.WaitForOutput(t, regexp.MustCompile(`Hello \w+`), 5*time.Second), "Hello first")
+	assert.NilError(t, result.SendLine("second"))
+	assert.Equal(t, result.WaitForOutput(t, regexp.MustCompile(`Hello \w+`), 5*time.Second), "Hello second")
+	assert.NilError(t, result.CloseStdin())
+
+	WaitOnCmd(5*time.Second, result).Assert(t, Expected{
+		Out: "Name: Hello first\nHello second\nbye\n",
+	})
+}
+
+func TestWaitForOutput_CommandExited(t *testing.T) {
+	buildStub(t)
+
+	result := StartCmd(Command(binname))
+	assert.NilError(t, result.Error)
+
+	ft := &fakePollT{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result.WaitForOutput(ft, regexp.MustCompile(`never`), 5*time.Second)
+	}()
+	<-done
+	assert.Assert(t, ft.failed)
+	assert.Assert(t, cmp.Contains(strings.Join(ft.logs, "\n"),
+		`the command exited and the output did not match "never": "this is stdout\n"`))
+}
+
+func TestSendLine_NoStdinPipe(t *testing.T) {
+	result := &Result{}
+	assert.Error(t, result.SendLine("x"), errNoStdinPipe.Error())
+	assert.Error(t, result.CloseStdin(), errNoStdinPipe.Error())
+}
+
+type fakePollT struct {
+	failed bool
+	logs   []string
+}
+
+func (t *fakePollT) Helper() {}
+
+func (t *fakePollT) Logf(format string, args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+
+func (t *fakePollT) FailNow() {
+	t.failed = true
+	runtime.Goexit()
+}
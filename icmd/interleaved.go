@@ -0,0 +1,134 @@
+package icmd
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chunk is part of the output of a command, from a single read of the stdout
+// or stderr pipe.
+type Chunk struct {
+	// Stream is the name of the stream that received the write, either
+	// "stdout" or "stderr".
+	Stream string
+	Data   string
+	// Time is when the output was read from the pipe.
+	Time time.Time
+}
+
+// WithInterleavedOutput adds the output of the command from both stdout and
+// stderr, in the order it was read, to the message from Result.String and
+// Result.Assert. See Result.Interleaved for the limits of the order.
+func WithInterleavedOutput() CmdOp {
+	return func(c *Cmd) {
+		c.interleaved = true
+	}
+}
+
+// chunkBuffer records the order of the output of a command from both stdout
+// and stderr. The output is only stored in the lockedBuffer of each stream, and
+// each chunk refers to the part of the buffer that was written.
+type chunkBuffer struct {
+	m      sync.Mutex
+	chunks []chunkRef
+}
+
+type chunkRef struct {
+	stream     string
+	buf        *lockedBuffer
+	start, end int
+	time       time.Time
+}
+
+// writer returns an io.Writer which writes to out, and records each write as a
+// chunk from stream.
+func (buf *chunkBuffer) writer(stream string, out *lockedBuffer) io.Writer {
+	return chunkWriter{buf: buf, stream: stream, out: out}
+}
+
+// all returns the chunks, with the data from the buffer of each stream.
+func (buf *chunkBuffer) all() []Chunk {
+	buf.m.Lock()
+	defer buf.m.Unlock()
+	contents := make(map[*lockedBuffer]string, 2)
+	chunks := make([]Chunk, 0, len(buf.chunks))
+	for _, ref := range buf.chunks {
+		content, ok := contents[ref.buf]
+		if !ok {
+			content = ref.buf.String()
+			contents[ref.buf] = content
+		}
+		chunks = append(chunks, Chunk{Stream: ref.stream, Data: content[ref.start:ref.end], Time: ref.time})
+	}
+	return chunks
+}
+
+// String returns the data from all the chunks.
+func (buf *chunkBuffer) String() string {
+	out := new(strings.Builder)
+	for _, chunk := range buf.all() {
+		out.WriteString(chunk.Data)
+	}
+	return out.String()
+}
+
+type chunkWriter struct {
+	buf    *chunkBuffer
+	stream string
+	out    *lockedBuffer
+}
+
+func (w chunkWriter) Write(b []byte) (int, error) {
+	w.buf.m.Lock()
+	defer w.buf.m.Unlock()
+	start := w.out.Len()
+	n, err := w.out.Write(b)
+	ref := chunkRef{stream: w.stream, buf: w.out, start: start, end: start + n, time: time.Now()}
+	w.buf.chunks = append(w.buf.chunks, ref)
+	return n, err
+}
+
+// Interleaved returns the output of the command from both stdout and stderr,
+// in the order it was read.
+//
+// stdout and stderr are read from separate pipes, so the order is the order
+// the pipes were read, not the order the command wrote the output. The order
+// of writes that happen at nearly the same time may not match the order the
+// command wrote them.
+func (r *Result) Interleaved() []Chunk {
+	if r.chunks == nil {
+		return nil
+	}
+	return r.chunks.all()
+}
+
+// formatInterleaved returns the lines of output, each prefixed with the name
+// of the stream.
+func formatInterleaved(chunks []Chunk) string {
+	out := new(strings.Builder)
+	var stream string
+	lineStart := true
+	for _, chunk := range chunks {
+		if chunk.Stream != stream && !lineStart {
+			out.WriteString("\n")
+			lineStart = true
+		}
+		stream = chunk.Stream
+		for _, line := range strings.SplitAfter(chunk.Data, "\n") {
+			if line == "" {
+				continue
+			}
+			if lineStart {
+				out.WriteString(stream + ": ")
+			}
+			out.WriteString(line)
+			lineStart = strings.HasSuffix(line, "\n")
+		}
+	}
+	if !lineStart {
+		out.WriteString("\n")
+	}
+	return out.String()
+}
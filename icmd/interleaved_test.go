@@ -0,0 +1,63 @@
+package icmd
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestResult_Interleaved(t *testing.T) {
+	buildStub(t)
+
+	result := RunCmd(Command(binname, "-warn", "-fail=2"), WithInterleavedOutput())
+	result.Assert(t, Expected{ExitCode: 2})
+
+	var streams []string
+	for _, chunk := range result.Interleaved() {
+		streams = append(streams, chunk.Stream)
+		assert.Assert(t, !chunk.Time.IsZero())
+	}
+	assert.Assert(t, cmp.Contains(streams, "stdout"))
+	assert.Assert(t, cmp.Contains(streams, "stderr"))
+
+	assert.Equal(t, result.Stdout(), "this is stdout\n")
+	assert.Equal(t, result.Stderr(), "this is stderr\n")
+
+	out := result.String()
+	assert.Assert(t, cmp.Contains(out, "\nStdout:   this is stdout\n"))
+	assert.Assert(t, cmp.Contains(out, "\nOutput:\n"))
+	assert.Assert(t, cmp.Contains(out, "stdout: this is stdout\n"))
+	assert.Assert(t, cmp.Contains(out, "stderr: this is stderr\n"))
+}
+
+func TestResult_StringWithoutInterleaved(t *testing.T) {
+	buildStub(t)
+
+	result := RunCommand(binname, "-warn", "-fail=2")
+	out := result.String()
+	assert.Assert(t, cmp.Contains(out, "\nStdout:   this is stdout\n"))
+	assert.Assert(t, cmp.Contains(out, "\nStderr:   this is stderr\n"))
+	assert.Assert(t, !strings.Contains(out, "Output:"))
+	assert.Equal(t, len(result.Interleaved()), 2)
+}
+
+func TestFormatInterleaved(t *testing.T) {
+	chunks := []Chunk{
+		{Stream: "stdout", Data: "first line\nsecond "},
+		{Stream: "stdout", Data: "line\n"},
+		{Stream: "stderr", Data: "warning: "},
+		{Stream: "stdout", Data: "third line\n\n"},
+		{Stream: "stderr", Data: "no newline"},
+	}
+	expected := `stdout: first line
+stdout: second line
+stderr: warning: 
+stdout: third line
+stdout: 
+stderr: no newline
+`
+	assert.Equal(t, formatInterleaved(chunks), expected)
+	assert.Equal(t, formatInterleaved(nil), "")
+}
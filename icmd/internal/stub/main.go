@@ -2,9 +2,12 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -12,10 +15,43 @@ func main() {
 	sleep := flag.Duration("sleep", 0, "Sleep")
 	warn := flag.Bool("warn", false, "Warn")
 	fail := flag.Int("fail", 0, "Fail with code")
+	onTerm := flag.String("on-term", "", "Exit or ignore when SIGTERM is received")
+	prompt := flag.Bool("prompt", false, "Prompt for a name on stdin")
+	tty := flag.Bool("tty", false, "Print if stdout is a terminal")
 	flag.Parse()
 
+	if *tty {
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Println("stdout is a \x1b[32mterminal\x1b[0m")
+		} else {
+			fmt.Println("stdout is not a terminal")
+		}
+	}
+
+	if *prompt {
+		fmt.Print("Name: ")
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			fmt.Printf("Hello %v\n", scanner.Text())
+		}
+		fmt.Println("bye")
+	}
+
+	signals := make(chan os.Signal, 1)
+	switch *onTerm {
+	case "exit":
+		signal.Notify(signals, syscall.SIGTERM)
+	case "ignore":
+		signal.Ignore(syscall.SIGTERM)
+	}
+
 	if *sleep != 0 {
-		time.Sleep(*sleep)
+		select {
+		case <-time.After(*sleep):
+		case sig := <-signals:
+			fmt.Printf("received %v\n", sig)
+			os.Exit(3)
+		}
 	}
 
 	fmt.Println("this is stdout")
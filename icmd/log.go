@@ -0,0 +1,77 @@
+package icmd
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/dnephin/vt/internal/cleanup"
+)
+
+// WithLogOutput forwards stdout and stderr of the command to t.Log one line at
+// a time, as the lines are written by the command. Each line is prefixed with
+// "stdout: " or "stderr: ". Any partial line is logged when the command exits.
+// Using `go test -v` with WithLogOutput shows the progress of slow commands,
+// and preserves the output if the test panics.
+//
+// Output written by the command after the test ends is not logged.
+// WithLogOutput can be used with WithStdout and WithStderr, in any order.
+func WithLogOutput(t TestingT) CmdOp {
+	stdout := &lineLogger{t: t, prefix: "stdout: "}
+	stderr := &lineLogger{t: t, prefix: "stderr: "}
+	cleanup.Cleanup(t, func() {
+		stdout.close()
+		stderr.close()
+	})
+	return func(c *Cmd) {
+		c.logStdout = append(c.logStdout, stdout)
+		c.logStderr = append(c.logStderr, stderr)
+		c.onExit = append(c.onExit, stdout.flush, stderr.flush)
+	}
+}
+
+// lineLogger is an io.Writer that logs each line using t.Log.
+type lineLogger struct {
+	m      sync.Mutex
+	t      TestingT
+	prefix string
+	buf    []byte
+	closed bool
+}
+
+func (l *lineLogger) Write(b []byte) (int, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.closed {
+		return len(b), nil
+	}
+	l.buf = append(l.buf, b...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.t.Log(l.prefix + string(l.buf[:i]))
+		l.buf = l.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush logs any partial line.
+func (l *lineLogger) flush() {
+	l.m.Lock()
+	defer l.m.Unlock()
+	if l.closed || len(l.buf) == 0 {
+		return
+	}
+	l.t.Log(l.prefix + string(l.buf))
+	l.buf = nil
+}
+
+// close flushes any partial line, and stops logging, because t.Log panics
+// after the test ends.
+func (l *lineLogger) close() {
+	l.flush()
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.closed = true
+}
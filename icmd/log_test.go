@@ -0,0 +1,49 @@
+package icmd
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWithLogOutput(t *testing.T) {
+	buildStub(t)
+
+	ft := &fakeT{}
+	result := RunCmd(Command(binname, "-warn"), WithLogOutput(ft))
+	result.Assert(t, Expected{Out: "this is stdout", Err: "this is stderr"})
+	assert.Assert(t, len(ft.cleanups) == 1)
+
+	// stdout and stderr are logged from different goroutines
+	sort.Strings(ft.logs)
+	assert.DeepEqual(t, ft.logs, []string{"stderr: this is stderr", "stdout: this is stdout"})
+}
+
+func TestWithLogOutput_BeforeWithStdout(t *testing.T) {
+	buildStub(t)
+
+	ft := &fakeT{}
+	stdout := new(bytes.Buffer)
+	result := RunCmd(Command(binname), WithLogOutput(ft), WithStdout(stdout))
+	result.Assert(t, Expected{Out: "this is stdout"})
+	assert.Equal(t, stdout.String(), "this is stdout\n")
+	assert.DeepEqual(t, ft.logs, []string{"stdout: this is stdout"})
+}
+
+func TestLineLogger(t *testing.T) {
+	ft := &fakeT{}
+	logger := &lineLogger{t: ft, prefix: "out: "}
+
+	_, _ = logger.Write([]byte("one\ntw"))
+	assert.DeepEqual(t, ft.logs, []string{"out: one"})
+	_, _ = logger.Write([]byte("o\n\nthree"))
+	assert.DeepEqual(t, ft.logs, []string{"out: one", "out: two", "out: "})
+	logger.flush()
+	assert.DeepEqual(t, ft.logs, []string{"out: one", "out: two", "out: ", "out: three"})
+
+	logger.close()
+	_, _ = logger.Write([]byte("four\n"))
+	assert.Equal(t, len(ft.logs), 4)
+}
@@ -0,0 +1,78 @@
+package icmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dnephin/vt/internal/format"
+)
+
+// Matcher compares the output of a command to an expected value, and returns an
+// error describing the difference if the output does not match. A Matcher is
+// used by Expected.OutMatcher and Expected.ErrMatcher. Any function with this
+// signature may be used to implement a custom comparison.
+type Matcher func(actual string) error
+
+// Exact returns a Matcher that succeeds if the output is equal to expected. The
+// failure message contains a unified diff of the output and expected, using the
+// same orientation as the golden package.
+func Exact(expected string) Matcher {
+	return func(actual string) error {
+		if actual == expected {
+			return nil
+		}
+		diff := format.UnifiedDiff(format.DiffConfig{
+			A:    actual,
+			B:    expected,
+			From: "got",
+			To:   "want",
+		})
+		return fmt.Errorf("is not equal to the expected value (-got +want):\n%s", diff)
+	}
+}
+
+// Regexp returns a Matcher that succeeds if the output matches the regular
+// expression pattern. The pattern is compiled using regexp.MustCompile.
+func Regexp(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(actual string) error {
+		if re.MatchString(actual) {
+			return nil
+		}
+		return fmt.Errorf("does not match the regular expression %q", pattern)
+	}
+}
+
+// Lines returns a Matcher that succeeds if every line in expected is equal to
+// a line in the output, in the same order. The output may contain other
+// lines before, between, and after the expected lines.
+func Lines(expected ...string) Matcher {
+	return func(actual string) error {
+		lines := strings.Split(actual, "\n")
+		i := 0
+		for _, line := range lines {
+			if i < len(expected) && line == expected[i] {
+				i++
+			}
+		}
+		if i == len(expected) {
+			return nil
+		}
+		if i == 0 {
+			return fmt.Errorf("is missing line %q", expected[i])
+		}
+		return fmt.Errorf("is missing line %q after line %q", expected[i], expected[i-1])
+	}
+}
+
+// NotContains returns a Matcher that succeeds if the output does not contain
+// substr.
+func NotContains(substr string) Matcher {
+	return func(actual string) error {
+		if !strings.Contains(actual, substr) {
+			return nil
+		}
+		return fmt.Errorf("contains %q", substr)
+	}
+}
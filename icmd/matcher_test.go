@@ -0,0 +1,70 @@
+package icmd
+
+import (
+	"os/exec"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExact(t *testing.T) {
+	assert.NilError(t, Exact("one\ntwo\n")("one\ntwo\n"))
+
+	err := Exact("one\ntwo\n")("one\nthree\n")
+	expected := `is not equal to the expected value (-got +want):
+--- got
++++ want
+@@ -1,3 +1,3 @@
+ one
+-three
++two
+ 
+`
+	assert.Error(t, err, expected)
+}
+
+func TestRegexp(t *testing.T) {
+	assert.NilError(t, Regexp(`version [0-9.]+`)("app version 1.2.3\n"))
+	assert.Error(t, Regexp(`^version`)("app version 1.2.3\n"),
+		`does not match the regular expression "^version"`)
+}
+
+func TestLines(t *testing.T) {
+	output := "one\ntwo\nthree\nfour\n"
+	assert.NilError(t, Lines("one", "three")(output))
+	assert.NilError(t, Lines()(output))
+	assert.Error(t, Lines("five")(output), `is missing line "five"`)
+	assert.Error(t, Lines("three", "two")(output), `is missing line "two" after line "three"`)
+	assert.Error(t, Lines("thr")(output), `is missing line "thr"`)
+}
+
+func TestNotContains(t *testing.T) {
+	assert.NilError(t, NotContains("panic")("all good\n"))
+	assert.Error(t, NotContains("panic")("panic: oops\n"), `contains "panic"`)
+}
+
+func TestResult_Match_Matchers(t *testing.T) {
+	result := &Result{
+		Cmd:       exec.Command("binary", "arg1"),
+		outBuffer: newLockedBuffer("the output"),
+		errBuffer: newLockedBuffer("the stderr"),
+	}
+	assert.NilError(t, result.match(Expected{
+		OutMatcher: Exact("the output"),
+		ErrMatcher: Regexp("^the"),
+	}))
+
+	err := result.match(Expected{
+		OutMatcher: Lines("other"),
+		ErrMatcher: NotContains("stderr"),
+	})
+	assert.Equal(t, err.Error(), `
+Command:  binary arg1
+ExitCode: 0
+Stdout:   the output
+Stderr:   the stderr
+
+Failures:
+Expected stdout to match, but it is missing line "other"
+Expected stderr to match, but it contains "stderr"`)
+}
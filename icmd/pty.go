@@ -0,0 +1,125 @@
+package icmd
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// PTYConfig configures the pseudo-terminal used by WithPTY.
+type PTYConfig struct {
+	// Rows is the height of the terminal. Defaults to 24.
+	Rows uint16
+	// Cols is the width of the terminal. Defaults to 80.
+	Cols uint16
+	// StripANSI removes ANSI escape sequences from the output returned by
+	// Result.Stdout and Result.Combined, and used by Result.Assert. The raw
+	// output is still available from Result.Interleaved.
+	StripANSI bool
+}
+
+// WithPTY runs the command attached to a pseudo-terminal, so that the command
+// behaves as it would when run from a terminal. Stdin, stdout, and stderr of
+// the command are all connected to the terminal, so all of the output is
+// captured as stdout. The terminal translates each \n written by the command
+// to \r\n.
+//
+// Input can be sent to the command using WithStdin, or Result.SendLine. When
+// WithPTY is used Result.CloseStdin sends an end-of-file (Ctrl-D) to the
+// terminal. The terminal only treats Ctrl-D as end-of-file in canonical mode,
+// at the start of a line. A command that puts the terminal in raw mode reads
+// Ctrl-D as a 0x04 byte, and stdin remains open.
+//
+// If a child process started by the command keeps the terminal open after the
+// command exits, any output written after a short delay is not captured.
+//
+// WithPTY is only supported on Linux. On other platforms the command fails to
+// start.
+func WithPTY(conf PTYConfig) CmdOp {
+	if conf.Rows == 0 {
+		conf.Rows = 24
+	}
+	if conf.Cols == 0 {
+		conf.Cols = 80
+	}
+	return func(c *Cmd) {
+		c.pty = &conf
+	}
+}
+
+// attachPTY connects stdin, stdout, and stderr of the command to a new
+// pseudo-terminal.
+func (r *Result) attachPTY(conf PTYConfig, stdin io.Reader) error {
+	tty, terminal, err := openPTY(conf)
+	if err != nil {
+		return err
+	}
+	output := r.Cmd.Stdout
+	r.Cmd.Stdin, r.Cmd.Stdout, r.Cmd.Stderr = terminal, terminal, terminal
+	setControllingTerminal(r.Cmd)
+	r.stripANSI = conf.StripANSI
+	r.stdin = ptyInput{tty: tty}
+
+	copied := make(chan struct{})
+	r.onStart = append(r.onStart, func(err error) {
+		// The terminal is only used by the command, and must be closed so that
+		// reading tty returns an error after the command exits.
+		_ = terminal.Close()
+		if err != nil {
+			_ = tty.Close()
+			close(copied)
+			return
+		}
+		if stdin != nil {
+			go func() {
+				_, _ = io.Copy(tty, stdin)
+			}()
+		}
+		go func() {
+			// Reading from the tty returns EIO once the command exits
+			_, _ = io.Copy(output, tty)
+			close(copied)
+		}()
+	})
+	// Wait for all the output to be copied before any other onExit functions.
+	// A child process started by the command may keep the terminal open after
+	// the command exits, so stop waiting after pipeWaitDelay.
+	r.onExit = append([]func(){func() {
+		timer := time.NewTimer(pipeWaitDelay)
+		defer timer.Stop()
+		select {
+		case <-copied:
+		case <-timer.C:
+		}
+		_ = tty.Close()
+	}}, r.onExit...)
+	return nil
+}
+
+// ptyInput sends input to the command through the pseudo-terminal.
+type ptyInput struct {
+	tty *os.File
+}
+
+func (p ptyInput) Write(b []byte) (int, error) {
+	return p.tty.Write(b)
+}
+
+// Close sends an end-of-file to the command, because closing the
+// pseudo-terminal would prevent reading the output. The end-of-file is only
+// received by a command that reads the terminal in canonical mode.
+func (p ptyInput) Close() error {
+	_, err := p.tty.Write([]byte{0x04})
+	return err
+}
+
+var ansiPattern = regexp.MustCompile(
+	"\x1b\\[[0-?]*[ -/]*[@-~]" + // control sequences, like colors and cursor movement
+		"|\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)" + // operating system commands, like the window title
+		"|\x1b[0-?@-Z\\\\-_a-~]") // other escape sequences
+
+// StripANSI returns s with all ANSI escape sequences removed.
+func StripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
@@ -0,0 +1,83 @@
+package icmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY opens a new pseudo-terminal, and returns the controlling side (tty),
+// and the terminal used by the command.
+func openPTY(conf PTYConfig) (tty *os.File, terminal *os.File, err error) {
+	tty, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tty.Close()
+		}
+	}()
+
+	var unlock int32
+	if err := ioctl(tty, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		return nil, nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+	var n uint32
+	if err := ioctl(tty, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		return nil, nil, fmt.Errorf("failed to get pseudo-terminal number: %w", err)
+	}
+	size := winsize{Rows: conf.Rows, Cols: conf.Cols}
+	if err := ioctl(tty, syscall.TIOCSWINSZ, unsafe.Pointer(&size)); err != nil {
+		return nil, nil, fmt.Errorf("failed to set pseudo-terminal size: %w", err)
+	}
+
+	name := "/dev/pts/" + strconv.Itoa(int(n))
+	terminal, err = os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+	return tty, terminal, nil
+}
+
+type winsize struct {
+	Rows uint16
+	Cols uint16
+	X    uint16
+	Y    uint16
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setControllingTerminal starts the command in a new session, with stdin as the
+// controlling terminal. The new session also creates a new process group, so
+// that the stop sequence signals all the processes in the group.
+func setControllingTerminal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Setsid, attr.Setctty, attr.Ctty = true, true, 0
+	// The session leader is already the leader of a new process group, and
+	// setpgid fails for a session leader.
+	attr.Setpgid = false
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package icmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+func openPTY(PTYConfig) (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("pseudo-terminal is not supported on %v", runtime.GOOS)
+}
+
+func setControllingTerminal(*exec.Cmd) {}
@@ -0,0 +1,93 @@
+package icmd
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestWithPTY(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WithPTY is only supported on linux")
+	}
+	buildStub(t)
+
+	result := RunCmd(Command(binname, "-tty", "-warn"))
+	result.Assert(t, Expected{Out: "stdout is not a terminal\n"})
+
+	result = RunCmd(Command(binname, "-tty", "-warn"), WithPTY(PTYConfig{}))
+	result.Assert(t, Expected{
+		Out: "stdout is a \x1b[32mterminal\x1b[0m\r\nthis is stdout\r\nthis is stderr\r\n",
+		Err: None,
+	})
+
+	result = RunCmd(Command(binname, "-tty"), WithPTY(PTYConfig{StripANSI: true}))
+	result.Assert(t, Expected{OutMatcher: Exact("stdout is a terminal\r\nthis is stdout\r\n")})
+	assert.Assert(t, cmp.Contains(result.Interleaved()[0].Data, "stdout is a \x1b[32m"))
+}
+
+func TestWithPTY_Size(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WithPTY is only supported on linux")
+	}
+	if _, err := exec.LookPath("stty"); err != nil {
+		t.Skip("requires stty")
+	}
+
+	result := RunCmd(Command("stty", "size"), WithPTY(PTYConfig{Rows: 30, Cols: 100}))
+	result.Assert(t, Expected{Out: "30 100\r\n"})
+}
+
+func TestWithPTY_Input(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WithPTY is only supported on linux")
+	}
+	buildStub(t)
+
+	result := StartCmd(Command(binname, "-prompt"), WithPTY(PTYConfig{}))
+	assert.NilError(t, result.Error)
+	result.WaitForOutput(t, regexp.MustCompile(`Name: `), 5*time.Second)
+	assert.NilError(t, result.SendLine("tty"))
+	result.WaitForOutput(t, regexp.MustCompile(`Hello tty`), 5*time.Second)
+	assert.NilError(t, result.CloseStdin())
+	WaitOnCmd(5*time.Second, result).Assert(t, Expected{Out: "bye\r\n"})
+}
+
+func TestWithPTY_StopSequence(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WithPTY is only supported on linux")
+	}
+	buildStub(t)
+
+	cmd := Command(binname, "-sleep=5s", "-on-term=exit")
+	cmd.Timeout = 200 * time.Millisecond
+	result := RunCmd(cmd,
+		WithStopSequence(StopSignal{Signal: syscall.SIGTERM, Wait: 5 * time.Second}),
+		WithPTY(PTYConfig{}))
+	result.Assert(t, Expected{Timeout: true, Out: "received terminated"})
+	assert.DeepEqual(t, result.Signals, []os.Signal{syscall.SIGTERM})
+}
+
+func TestWithPTY_ChildHoldsTerminal(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WithPTY is only supported on linux")
+	}
+
+	start := time.Now()
+	// ignore SIGHUP, so that sleep continues to run after sh exits
+	result := RunCmd(Command("sh", "-c", "trap '' HUP; sleep 3 & echo done"), WithPTY(PTYConfig{}))
+	result.Assert(t, Expected{Out: "done\r\n"})
+	assert.Assert(t, time.Since(start) < 2*time.Second)
+}
+
+func TestStripANSI(t *testing.T) {
+	input := "\x1b[1;31merror\x1b[0m: \x1b]0;title\x07failed\x1b[2K\x1b7"
+	assert.Equal(t, StripANSI(input), "error: failed")
+}
@@ -0,0 +1,101 @@
+package icmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// StopSignal is a step in the sequence used to stop a command. See
+// WithStopSequence.
+type StopSignal struct {
+	// Signal is sent to the command.
+	Signal os.Signal
+	// Wait is the time to wait for the command to exit after sending Signal,
+	// before moving on to the next step.
+	Wait time.Duration
+}
+
+// WithStopSequence sets the sequence of signals used to stop the command when
+// it reaches the timeout, or when the context passed to RunCmdContext is done.
+// If the command has not exited after the last step it is killed. For example,
+// to give the command a chance to flush logs before it is killed:
+//
+//	icmd.WithStopSequence(icmd.StopSignal{Signal: syscall.SIGTERM, Wait: 2 * time.Second})
+//
+// On unix the command is run in a new process group, and the signals are sent
+// to every process in the group, so that child processes started by the
+// command are also stopped. On windows only os.Kill is supported.
+//
+// The signals sent to the command are recorded in Result.Signals.
+func WithStopSequence(steps ...StopSignal) CmdOp {
+	return func(c *Cmd) {
+		c.StopSequence = steps
+	}
+}
+
+// pipeWaitDelay is the time to wait for the stdout and stderr pipes to close
+// after the command exits.
+const pipeWaitDelay = time.Second
+
+// stopDuration returns the total time to wait for the steps of a stop
+// sequence.
+func stopDuration(steps []StopSignal) time.Duration {
+	var total time.Duration
+	for _, step := range steps {
+		total += step.Wait
+	}
+	return total
+}
+
+// stop the command by sending each signal in the stop sequence, and waiting
+// for the command to exit. The command is killed if it does not exit after the
+// last step.
+func (r *Result) stop() {
+	r.stopOnce.Do(func() {
+		for _, step := range r.stopSequence {
+			if !r.signal(step.Signal) {
+				return
+			}
+			select {
+			case <-r.exited:
+				return
+			case <-time.After(step.Wait):
+			}
+		}
+		r.signal(os.Kill)
+	})
+}
+
+// signal sends sig to the command, and returns false if the command has
+// already exited.
+func (r *Result) signal(sig os.Signal) bool {
+	select {
+	case <-r.exited:
+		return false
+	default:
+	}
+	if r.Cmd.Process == nil {
+		return false
+	}
+
+	err := signalProcess(r.Cmd, sig)
+	switch {
+	case errors.Is(err, os.ErrProcessDone):
+		return false
+	case err != nil:
+		fmt.Printf("failed to send %v (pid=%d): %v\n", sig, r.Cmd.Process.Pid, err)
+		return true
+	}
+	r.signalsLock.Lock()
+	defer r.signalsLock.Unlock()
+	r.Signals = append(r.Signals, sig)
+	return true
+}
+
+func (r *Result) sentSignals() []os.Signal {
+	r.signalsLock.Lock()
+	defer r.signalsLock.Unlock()
+	return r.Signals
+}
@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+package icmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs the command in a new process group, so that signals can
+// be sent to any child processes started by the command.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcess sends sig to every process in the process group of cmd, when
+// cmd was started in a new process group. Otherwise sig is only sent to the
+// process.
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok || !isGroupLeader(cmd) {
+		return cmd.Process.Signal(sig)
+	}
+	err := syscall.Kill(-cmd.Process.Pid, s)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// isGroupLeader returns true if cmd was started in a new process group. A new
+// session also starts a new process group.
+func isGroupLeader(cmd *exec.Cmd) bool {
+	attr := cmd.SysProcAttr
+	return attr != nil && (attr.Setpgid || attr.Setsid)
+}
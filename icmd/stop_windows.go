@@ -0,0 +1,15 @@
+package icmd
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(*exec.Cmd) {}
+
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	if sig == os.Kill {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,20 @@
+package icmd
+
+import "time"
+
+// recordUsage sets the duration and resource usage of the command after it
+// exits.
+func (r *Result) recordUsage() {
+	if r.started.IsZero() {
+		return
+	}
+	r.Duration = time.Since(r.started)
+
+	state := r.Cmd.ProcessState
+	if state == nil {
+		return
+	}
+	r.UserTime = state.UserTime()
+	r.SystemTime = state.SystemTime()
+	r.MaxRSS = maxRSS(state)
+}
@@ -0,0 +1,14 @@
+package icmd
+
+import (
+	"os"
+	"syscall"
+)
+
+func maxRSS(state *os.ProcessState) int64 {
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// Maxrss is in kilobytes on linux
+		return int64(usage.Maxrss) * 1024
+	}
+	return 0
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package icmd
+
+import "os"
+
+func maxRSS(*os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,44 @@
+package icmd
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestResult_ResourceUsage(t *testing.T) {
+	buildStub(t)
+
+	result := RunCommand(binname, "-sleep=50ms")
+	result.Assert(t, Expected{MaxDuration: time.Minute, MaxCPUTime: time.Minute, MaxRSS: 1 << 40})
+	assert.Assert(t, result.Duration >= 50*time.Millisecond, result.Duration)
+	if runtime.GOOS == "linux" {
+		assert.Assert(t, result.MaxRSS > 0)
+	}
+}
+
+func TestResult_Match_ResourceUsage(t *testing.T) {
+	result := &Result{
+		Cmd:        exec.Command("binary"),
+		outBuffer:  newLockedBuffer(""),
+		errBuffer:  newLockedBuffer(""),
+		Duration:   2 * time.Second,
+		UserTime:   time.Second,
+		SystemTime: 500 * time.Millisecond,
+		MaxRSS:     2048,
+	}
+	err := result.match(Expected{
+		MaxDuration: time.Second,
+		MaxCPUTime:  time.Second,
+		MaxRSS:      1024,
+	})
+	assert.Assert(t, cmp.Contains(err.Error(), `
+Failures:
+Duration was 2s expected at most 1s
+CPU time was 1.5s expected at most 1s
+MaxRSS was 2048 bytes expected at most 1024 bytes`))
+}